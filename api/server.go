@@ -0,0 +1,450 @@
+// Package api serves a local HTTP API for observing and controlling a running Swarm,
+// so it can be driven from scripts and dashboards.
+//
+// As a Swarm can't be used concurrently with it's Update, the requests are queued as commands
+// that are run by the simulation's own goroutine, see Server.Process.
+//
+//	GET    /snapshot          The Swarm's current Snapshot, as JSON.
+//	GET    /flock             Flock metrics (polarization, milling etc.), as JSON.
+//	GET    /conf              The current Conf, as JSON.
+//	PATCH  /conf              Changes some of the Conf's fields live (see Swarm.Tune).
+//	PUT    /target            Sets the target, as {"X": 100, "Y": 200}.
+//	DELETE /target            Clears the target, going back to the simulation's own.
+//	POST   /food              Drops food, that the Swarm swims to until it's eaten.
+//	POST   /predator          Strikes with a predator, startling and eating nearby Boids.
+//	POST   /pause             Pauses the simulation.
+//	POST   /resume            Resumes the simulation.
+//	POST   /step?n=1          Runs n updates while paused.
+//	GET    /stream?every=2    Streams the Boids' positions every n:th tick, as server-sent events.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lmas/akvarium/boids"
+	"github.com/lmas/akvarium/boids/metrics"
+)
+
+// Boids within this distance (in pixels) eats the food.
+const eatRadius float64 = 10
+
+// Predators eats all Boids within this part of their strike radius.
+const predatorBite float64 = 0.25
+
+// Max number of queued commands, before requests has to wait.
+const maxCommands int = 64
+
+// Max number of buffered stream messages per client, before dropping messages to slow clients.
+const maxStreamBuffer int = 16
+
+type command struct {
+	fn   func(s *boids.Swarm)
+	done chan struct{}
+}
+
+type stream struct {
+	ch    chan []byte
+	every uint64
+}
+
+// Server is a http.Handler for controlling a Swarm.
+type Server struct {
+	swarm *boids.Swarm
+	mux   *http.ServeMux
+	cmds  chan command
+
+	// Only used by the simulation's goroutine.
+	paused bool
+	steps  int
+	target *boids.Vector
+	food   []boids.Vector
+
+	mu      sync.Mutex // Protects streams.
+	streams map[*stream]bool
+}
+
+// New returns a Server controlling the Swarm.
+func New(s *boids.Swarm) *Server {
+	srv := &Server{
+		swarm:   s,
+		mux:     http.NewServeMux(),
+		cmds:    make(chan command, maxCommands),
+		streams: make(map[*stream]bool),
+	}
+	srv.mux.HandleFunc("/snapshot", srv.handleSnapshot)
+	srv.mux.HandleFunc("/flock", srv.handleFlock)
+	srv.mux.HandleFunc("/conf", srv.handleConf)
+	srv.mux.HandleFunc("/target", srv.handleTarget)
+	srv.mux.HandleFunc("/food", srv.handleFood)
+	srv.mux.HandleFunc("/predator", srv.handlePredator)
+	srv.mux.HandleFunc("/pause", srv.handlePause)
+	srv.mux.HandleFunc("/resume", srv.handlePause)
+	srv.mux.HandleFunc("/step", srv.handleStep)
+	srv.mux.HandleFunc("/stream", srv.handleStream)
+	return srv
+}
+
+// Handle adds another handler to the Server, for example for serving metrics.
+func (srv *Server) Handle(pattern string, h http.Handler) {
+	srv.mux.Handle(pattern, h)
+}
+
+// ServeHTTP implements http.Handler.
+func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	srv.mux.ServeHTTP(w, r)
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// These are called by the simulation's goroutine
+
+// Process runs all queued commands. It must be called before each Update, from the same goroutine.
+func (srv *Server) Process() {
+	for {
+		select {
+		case c := <-srv.cmds:
+			c.fn(srv.swarm)
+			close(c.done)
+		default:
+			return
+		}
+	}
+}
+
+// Running returns false if the simulation is paused and shouldn't Update.
+// Each call uses up one of the requested steps, while paused.
+func (srv *Server) Running() bool {
+	if !srv.paused {
+		return true
+	}
+	if srv.steps > 0 {
+		srv.steps--
+		return true
+	}
+	return false
+}
+
+// Target returns the target set by the API, or else the fallback.
+// Any food has priority over the target.
+func (srv *Server) Target(fallback boids.Vector) boids.Vector {
+	if len(srv.food) > 0 {
+		return srv.food[0]
+	}
+	if srv.target != nil {
+		return *srv.target
+	}
+	return fallback
+}
+
+// Updated lets the Swarm eat the food and streams the new positions to any clients.
+// It must be called after each Update, from the same goroutine.
+func (srv *Server) Updated() {
+	if len(srv.food) > 0 {
+		f := srv.food[0]
+		for _, b := range srv.swarm.Boids {
+			if b.Pos.DistanceSq(f) <= eatRadius*eatRadius {
+				srv.food = srv.food[1:]
+				break
+			}
+		}
+	}
+	srv.broadcast()
+}
+
+func (srv *Server) broadcast() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.streams) < 1 {
+		return
+	}
+	tick := srv.swarm.Tick()
+	var msg []byte
+	for st := range srv.streams {
+		if tick%st.every != 0 {
+			continue
+		}
+		if msg == nil {
+			msg = srv.positions(tick)
+		}
+		select {
+		case st.ch <- msg:
+		default:
+			// Drops the message for slow clients, instead of blocking the simulation
+		}
+	}
+}
+
+// positions returns a server-sent event with the tick and positions of all Boids, by ID.
+func (srv *Server) positions(tick uint64) []byte {
+	buf := make([]byte, 0, 32+len(srv.swarm.Boids)*16)
+	buf = append(buf, `data: {"tick":`...)
+	buf = strconv.AppendUint(buf, tick, 10)
+	buf = append(buf, `,"boids":[`...)
+	for i, b := range srv.swarm.Boids {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, '[')
+		buf = strconv.AppendFloat(buf, b.Pos.X, 'f', 2, 64)
+		buf = append(buf, ',')
+		buf = strconv.AppendFloat(buf, b.Pos.Y, 'f', 2, 64)
+		buf = append(buf, ']')
+	}
+	return append(buf, "]}\n\n"...)
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// HANDLERS
+
+// do queues a command and waits until the simulation has run it.
+func (srv *Server) do(ctx context.Context, fn func(s *boids.Swarm)) error {
+	c := command{fn, make(chan struct{})}
+	select {
+	case srv.cmds <- c:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func allow(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, m := range methods {
+		if r.Method == m {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	return false
+}
+
+func reply(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, fmt.Sprintf("bad json: %s", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func (srv *Server) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	var snap *boids.Snapshot
+	if err := srv.do(r.Context(), func(s *boids.Swarm) { snap = s.Snapshot() }); err != nil {
+		return
+	}
+	reply(w, snap)
+}
+
+func (srv *Server) handleFlock(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	var m metrics.Metrics
+	if err := srv.do(r.Context(), func(s *boids.Swarm) { m = metrics.Compute(s) }); err != nil {
+		return
+	}
+	reply(w, m)
+}
+
+func (srv *Server) handleConf(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet, http.MethodPatch) {
+		return
+	}
+	// Tune is safe to use concurrently, so this doesn't need a command
+	if r.Method == http.MethodPatch {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Checked first, as Tune would apply a partially decoded conf
+		var c boids.Conf
+		if err := json.Unmarshal(body, &c); err != nil {
+			http.Error(w, fmt.Sprintf("bad json: %s", err), http.StatusBadRequest)
+			return
+		}
+		// Decoded on top of the latest conf while holding the Swarm's lock, so changes made
+		// at the same time (by other requests or a config file) aren't lost
+		err = srv.swarm.Tune(func(c *boids.Conf) {
+			_ = json.Unmarshal(body, c) // Can't fail, as it was decoded above
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	reply(w, srv.swarm.CurrentConf())
+}
+
+func (srv *Server) handleTarget(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodPut, http.MethodDelete) {
+		return
+	}
+	var target *boids.Vector
+	if r.Method == http.MethodPut {
+		target = &boids.Vector{}
+		if !decode(w, r, target) {
+			return
+		}
+	}
+	if err := srv.do(r.Context(), func(s *boids.Swarm) { srv.target = target }); err != nil {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (srv *Server) handleFood(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodPost) {
+		return
+	}
+	var pos boids.Vector
+	if !decode(w, r, &pos) {
+		return
+	}
+	if err := srv.do(r.Context(), func(s *boids.Swarm) { srv.food = append(srv.food, pos) }); err != nil {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Predator strikes at a position.
+type Predator struct {
+	X, Y   float64
+	Radius float64
+}
+
+func (srv *Server) handlePredator(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodPost) {
+		return
+	}
+	var p Predator
+	if !decode(w, r, &p) {
+		return
+	}
+	if p.Radius <= 0 {
+		http.Error(w, "radius must be larger than 0", http.StatusBadRequest)
+		return
+	}
+	pos := boids.NewVector(p.X, p.Y)
+	eaten := []int{}
+	err := srv.do(r.Context(), func(s *boids.Swarm) {
+		bite := p.Radius * predatorBite
+		for _, b := range s.Boids {
+			if b.Pos.DistanceSq(pos) <= bite*bite {
+				eaten = append(eaten, b.ID)
+			}
+		}
+		for _, id := range eaten {
+			s.Eat(id)
+		}
+		s.Startle(pos, p.Radius)
+	})
+	if err != nil {
+		return
+	}
+	reply(w, struct{ Eaten []int }{eaten})
+}
+
+func (srv *Server) handlePause(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodPost) {
+		return
+	}
+	paused := r.URL.Path == "/pause"
+	if err := srv.do(r.Context(), func(s *boids.Swarm) { srv.paused, srv.steps = paused, 0 }); err != nil {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (srv *Server) handleStep(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodPost) {
+		return
+	}
+	n := 1
+	if q := r.URL.Query().Get("n"); q != "" {
+		var err error
+		if n, err = strconv.Atoi(q); err != nil || n < 1 {
+			http.Error(w, "n must be a number larger than 0", http.StatusBadRequest)
+			return
+		}
+	}
+	var paused bool
+	if err := srv.do(r.Context(), func(s *boids.Swarm) {
+		paused = srv.paused
+		if paused {
+			srv.steps += n
+		}
+	}); err != nil {
+		return
+	}
+	if !paused {
+		http.Error(w, "must be paused", http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (srv *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	st := &stream{ch: make(chan []byte, maxStreamBuffer), every: 2}
+	if q := r.URL.Query().Get("every"); q != "" {
+		every, err := strconv.ParseUint(q, 10, 64)
+		if err != nil || every < 1 {
+			http.Error(w, "every must be a number larger than 0", http.StatusBadRequest)
+			return
+		}
+		st.every = every
+	}
+
+	srv.mu.Lock()
+	srv.streams[st] = true
+	srv.mu.Unlock()
+	defer func() {
+		srv.mu.Lock()
+		delete(srv.streams, st)
+		srv.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case msg := <-st.ch:
+			if _, err := w.Write(msg); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
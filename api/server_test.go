@@ -0,0 +1,312 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lmas/akvarium/boids"
+	"github.com/lmas/akvarium/boids/metrics"
+)
+
+var center = boids.NewVector(320, 180)
+
+// testServer runs a simulation in the background, like the main loop, until the test is done.
+func testServer(t *testing.T) (*Server, *httptest.Server) {
+	t.Helper()
+	conf := boids.DefaultConf()
+	conf.Boids = 50
+	conf.Spawn = [2]boids.Vector{boids.NewVector(0, 0), boids.NewVector(640, 360)}
+	s, err := boids.New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := New(s)
+	ts := httptest.NewServer(srv)
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Millisecond):
+			}
+			srv.Process()
+			if srv.Running() {
+				s.Update(s.Tick()%2 == 0, srv.Target(center))
+				srv.Updated()
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		ts.Close()
+		close(stop)
+		<-done
+		s.Close()
+	})
+	return srv, ts
+}
+
+func request(t *testing.T, method, url, body string, v interface{}) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if v != nil && resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// tick returns the Swarm's current tick, via a snapshot.
+func tick(t *testing.T, url string) uint64 {
+	t.Helper()
+	var snap boids.Snapshot
+	if code := request(t, "GET", url+"/snapshot", "", &snap); code != http.StatusOK {
+		t.Fatalf("got status %d, expected 200", code)
+	}
+	return snap.Tick
+}
+
+func TestObserve(t *testing.T) {
+	_, ts := testServer(t)
+	t.Run("snapshot", func(t *testing.T) {
+		var snap boids.Snapshot
+		if code := request(t, "GET", ts.URL+"/snapshot", "", &snap); code != http.StatusOK {
+			t.Fatalf("got status %d, expected 200", code)
+		}
+		if len(snap.Boids) != 50 {
+			t.Errorf("got %d boids, expected 50", len(snap.Boids))
+		}
+	})
+	t.Run("flock", func(t *testing.T) {
+		var m metrics.Metrics
+		if code := request(t, "GET", ts.URL+"/flock", "", &m); code != http.StatusOK {
+			t.Fatalf("got status %d, expected 200", code)
+		}
+		if m.Boids != 50 || m.Speed.Mean <= 0 {
+			t.Errorf("got %+v, expected metrics for 50 moving boids", m)
+		}
+	})
+	t.Run("method", func(t *testing.T) {
+		if code := request(t, "POST", ts.URL+"/snapshot", "", nil); code != http.StatusMethodNotAllowed {
+			t.Errorf("got status %d, expected 405", code)
+		}
+	})
+}
+
+func TestConf(t *testing.T) {
+	_, ts := testServer(t)
+	var conf boids.Conf
+	if code := request(t, "PATCH", ts.URL+"/conf", `{"CohesionFactor": 0.5}`, &conf); code != http.StatusOK {
+		t.Fatalf("got status %d, expected 200", code)
+	}
+	if conf.CohesionFactor != 0.5 || conf.Boids != 50 {
+		t.Errorf("got cohesion %f and %d boids, expected 0.5 and 50", conf.CohesionFactor, conf.Boids)
+	}
+	if code := request(t, "GET", ts.URL+"/conf", "", &conf); code != http.StatusOK || conf.CohesionFactor != 0.5 {
+		t.Errorf("got status %d and cohesion %f, expected 200 and 0.5", code, conf.CohesionFactor)
+	}
+	if code := request(t, "PATCH", ts.URL+"/conf", `{"VelocityMax": -1}`, nil); code != http.StatusBadRequest {
+		t.Errorf("got status %d for an invalid conf, expected 400", code)
+	}
+	if code := request(t, "PATCH", ts.URL+"/conf", `{"VelocityMax": `, nil); code != http.StatusBadRequest {
+		t.Errorf("got status %d for bad json, expected 400", code)
+	}
+	if code := request(t, "PATCH", ts.URL+"/conf", `{"CohesionFactor": 0.7, "Boids": "x"}`, nil); code != http.StatusBadRequest {
+		t.Errorf("got status %d for a bad field, expected 400", code)
+	}
+	if request(t, "GET", ts.URL+"/conf", "", &conf); conf.CohesionFactor != 0.5 {
+		t.Errorf("got cohesion %f, expected 0.5 to be kept after bad json", conf.CohesionFactor)
+	}
+
+	// Concurrent changes to different fields must all be kept
+	fields := []string{"AlignmentFactor", "SeparationFactor", "TargetRepelFactor", "TargetAttractFactor"}
+	done := make(chan int)
+	for _, f := range fields {
+		go func(f string) {
+			// Not using request, as it can't fail the test from another goroutine
+			req, _ := http.NewRequest("PATCH", ts.URL+"/conf", strings.NewReader(fmt.Sprintf(`{"%s": 0.25}`, f)))
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				done <- 0
+				return
+			}
+			resp.Body.Close()
+			done <- resp.StatusCode
+		}(f)
+	}
+	for range fields {
+		if code := <-done; code != http.StatusOK {
+			t.Errorf("got status %d, expected 200", code)
+		}
+	}
+	request(t, "GET", ts.URL+"/conf", "", &conf)
+	for _, f := range []float64{conf.AlignmentFactor, conf.SeparationFactor, conf.TargetRepelFactor, conf.TargetAttractFactor} {
+		if f != 0.25 {
+			t.Errorf("got %+v, expected all factors to be 0.25", conf)
+			break
+		}
+	}
+}
+
+func TestTargetAndFood(t *testing.T) {
+	srv, ts := testServer(t)
+	target := boids.NewVector(10, 20)
+	if code := request(t, "PUT", ts.URL+"/target", `{"X": 10, "Y": 20}`, nil); code != http.StatusNoContent {
+		t.Fatalf("got status %d, expected 204", code)
+	}
+	// Commands are run by the simulation, so the state is checked with another command
+	check := func(expected boids.Vector) {
+		t.Helper()
+		var got boids.Vector
+		srv.do(context.Background(), func(s *boids.Swarm) { got = srv.Target(center) })
+		if got != expected {
+			t.Errorf("got target %s, expected %s", got, expected)
+		}
+	}
+	check(target)
+
+	// Drops the food right on top of a boid, so it's eaten straight away
+	var snap boids.Snapshot
+	request(t, "GET", ts.URL+"/snapshot", "", &snap)
+	pos := snap.Boids[0].Pos
+	body, _ := json.Marshal(pos)
+	if code := request(t, "POST", ts.URL+"/food", string(body), nil); code != http.StatusNoContent {
+		t.Fatalf("got status %d, expected 204", code)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		var food int
+		srv.do(context.Background(), func(s *boids.Swarm) { food = len(srv.food) })
+		if food == 0 {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("got %d food left, expected it to be eaten", food)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	check(target)
+
+	if code := request(t, "DELETE", ts.URL+"/target", "", nil); code != http.StatusNoContent {
+		t.Fatalf("got status %d, expected 204", code)
+	}
+	check(center)
+}
+
+func TestPredator(t *testing.T) {
+	_, ts := testServer(t)
+	request(t, "POST", ts.URL+"/pause", "", nil)
+	var snap boids.Snapshot
+	request(t, "GET", ts.URL+"/snapshot", "", &snap)
+	pos := snap.Boids[0].Pos
+
+	var resp struct{ Eaten []int }
+	body := fmt.Sprintf(`{"X": %v, "Y": %v, "Radius": 40}`, pos.X, pos.Y)
+	if code := request(t, "POST", ts.URL+"/predator", body, &resp); code != http.StatusOK {
+		t.Fatalf("got status %d, expected 200", code)
+	}
+	found := false
+	for _, id := range resp.Eaten {
+		found = found || id == 0
+	}
+	if !found {
+		t.Errorf("got eaten %v, expected boid 0 to be eaten", resp.Eaten)
+	}
+	request(t, "GET", ts.URL+"/snapshot", "", &snap)
+	if snap.Boids[0].Pos == pos {
+		t.Errorf("got boid 0 at the same position, expected it to respawn")
+	}
+	if code := request(t, "POST", ts.URL+"/predator", `{"X": 1, "Y": 1}`, nil); code != http.StatusBadRequest {
+		t.Errorf("got status %d without a radius, expected 400", code)
+	}
+}
+
+func TestPauseAndStep(t *testing.T) {
+	_, ts := testServer(t)
+	if code := request(t, "POST", ts.URL+"/step", "", nil); code != http.StatusConflict {
+		t.Errorf("got status %d when running, expected 409", code)
+	}
+	if code := request(t, "POST", ts.URL+"/pause", "", nil); code != http.StatusNoContent {
+		t.Fatalf("got status %d, expected 204", code)
+	}
+	start := tick(t, ts.URL)
+	time.Sleep(10 * time.Millisecond)
+	if got := tick(t, ts.URL); got != start {
+		t.Fatalf("got tick %d while paused, expected %d", got, start)
+	}
+
+	if code := request(t, "POST", ts.URL+"/step?n=3", "", nil); code != http.StatusNoContent {
+		t.Fatalf("got status %d, expected 204", code)
+	}
+	deadline := time.Now().Add(time.Second)
+	for tick(t, ts.URL) < start+3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if got := tick(t, ts.URL); got != start+3 {
+		t.Errorf("got tick %d after stepping, expected %d", got, start+3)
+	}
+	if code := request(t, "POST", ts.URL+"/step?n=0", "", nil); code != http.StatusBadRequest {
+		t.Errorf("got status %d for 0 steps, expected 400", code)
+	}
+
+	request(t, "POST", ts.URL+"/resume", "", nil)
+	deadline = time.Now().Add(time.Second)
+	for tick(t, ts.URL) <= start+3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("got no updates after resuming")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestStream(t *testing.T) {
+	_, ts := testServer(t)
+	resp, err := http.Get(ts.URL + "/stream?every=4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("got content type %q, expected text/event-stream", ct)
+	}
+
+	r := bufio.NewReader(resp.Body)
+	for i := 0; i < 3; i++ {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.ReadString('\n'); err != nil && err != io.EOF {
+			t.Fatal(err)
+		}
+		var msg struct {
+			Tick  uint64
+			Boids [][2]float64
+		}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &msg); err != nil {
+			t.Fatalf("got bad event %q: %s", line, err)
+		}
+		if msg.Tick%4 != 0 || len(msg.Boids) != 50 {
+			t.Errorf("got tick %d with %d boids, expected every 4th tick with 50 boids", msg.Tick, len(msg.Boids))
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package boids
+
+// Alarm levels below this are rounded down to 0, so alarms eventually die out.
+const minAlarm float64 = 0.01
+
+// alarm keeps track of a Boid's alarm state between updates.
+type alarm struct {
+	level   float64 // Next alarm level.
+	pending float64 // Alarm level heard from a neighbour, waiting for the delay.
+	delay   int     // Updates left before the pending alarm is raised.
+}
+
+// Startle alarms all Boids within radius of pos, like tapping on the glass of the aquarium.
+// The alarm then spreads out through the Swarm as a startle wave, to any neighbours within
+// Conf.AlarmRange, and fades away over time.
+// It must not be called concurrently with Update and does nothing in Conf.FixedPoint mode.
+func (s *Swarm) Startle(pos Vector, radius float64) {
+	if s.Conf.FixedPoint {
+		return
+	}
+	if s.recorder != nil {
+		s.recorder.write(recStartle, pos.X, pos.Y, radius)
+	}
+	for _, b := range s.Boids {
+		if pos.DistanceSq(b.Pos) <= radius*radius {
+			b.Alarm = 1
+			b.alarm = alarm{level: 1}
+		}
+	}
+}
+
+// hearAlarm returns the neighbour's alarm level if it's loud and close enough, or else
+// the loudest level heard so far.
+func (s *Swarm) hearAlarm(b, n *Boid, loudest float64) float64 {
+	if n.Alarm < s.Conf.AlarmThreshold {
+		return loudest
+	}
+	if n.Pos.DistanceSq(b.Pos) > s.squareAlarmRange {
+		return loudest
+	}
+	return n.Alarm
+}
+
+// updateAlarm fades the Boid's own alarm and raises any alarm heard from the neighbours,
+// after the delay has passed.
+// Only the Boid's private alarm state is written, so neighbours can keep reading the current
+// level while the workers are running.
+func (s *Swarm) updateAlarm(b *Boid, loudest float64) {
+	a := &b.alarm
+	a.level = b.Alarm * (1 - s.Conf.AlarmDecay)
+	if a.level < minAlarm {
+		a.level = 0
+	}
+	if heard := loudest * (1 - s.Conf.AlarmDecay); heard > a.level && heard > a.pending {
+		a.pending = heard
+		a.delay = s.Conf.AlarmDelay
+	}
+	if a.pending > 0 {
+		if a.delay > 0 {
+			a.delay--
+			return
+		}
+		if a.pending > a.level {
+			a.level = a.pending
+		}
+		a.pending = 0
+	}
+}
@@ -0,0 +1,45 @@
+package boids
+
+import (
+	"testing"
+)
+
+func TestStartle(t *testing.T) {
+	s := mustNew(t, Conf{
+		Boids:          10,
+		Workers:        2,
+		IndexOffset:    50,
+		AlarmRange:     15,
+		AlarmDelay:     1,
+		AlarmDecay:     0.1,
+		AlarmThreshold: 0.5,
+	})
+	placeRow(s)
+	s.Startle(NewVector(0, 0), 1)
+	if s.Boids[0].Alarm != 1 || s.Boids[1].Alarm != 0 {
+		t.Fatalf("got alarms %f and %f, expected only the first boid startled", s.Boids[0].Alarm, s.Boids[1].Alarm)
+	}
+
+	// The wave moves one boid per 2 updates, as it's delayed by one update
+	for i := 0; i < 4; i++ {
+		s.Update(true, NewVector(0, 0))
+	}
+	for i, b := range s.Boids[:3] {
+		if b.Alarm == 0 {
+			t.Errorf("got no alarm for boid %d, expected the wave to reach it", i)
+		}
+	}
+	if s.Boids[3].Alarm != 0 {
+		t.Errorf("got alarm %f for boid 3, expected the wave to not have reached it yet", s.Boids[3].Alarm)
+	}
+
+	// And it should fade away completely after a while
+	for i := 0; i < 100; i++ {
+		s.Update(true, NewVector(0, 0))
+	}
+	for i, b := range s.Boids {
+		if b.Alarm != 0 {
+			t.Errorf("got alarm %f for boid %d, expected the wave to fade away", b.Alarm, i)
+		}
+	}
+}
@@ -8,16 +8,77 @@ import "math"
 // - matching with nearby Boids' velocity (Alignment).
 // - avoiding collisions with nearby Boids (Separation).
 //
-// It can optionally move towards a target.
+// It can optionally move towards a target, or follow a Route.
 type Boid struct {
-	ID  int
-	Pos Vector
-	Vel Vector
+	ID      int
+	Pos     Vector
+	Vel     Vector
+	Heading float64 // Direction (in radians) the Boid is facing, turning smoothly towards Vel.
+	Traits  Traits
+	Role    Role
+	Goal    Goal    // What an informed Boid steers towards.
+	Alarm   float64 // How startled the Boid is, from 0 (calm) to 1 (panic).
+
+	route   *Route // Route to follow instead of the Swarm's target, if set.
+	regions uint64 // Bitmask of the regions the Boid is inside.
+	next    Vector // New velocity, committed after all workers are done reading the old one.
+	alarm   alarm  // Next alarm level and any alarm about to be passed on from a neighbour.
+	push    Vector // Correction for overlapping Boids, see collide.
+	fx      fixedState
+}
+
+// Role decides if a Boid has any other goals, besides fitting in with the Swarm.
+// Based on the informed individuals from "Effective leadership and decision-making in
+// animal groups on the move" (Couzin et al. 2005).
+type Role int
+
+const (
+	RoleDefault  Role = iota // Follows the social rules and the Swarm's target, or it's Route.
+	RoleNaive                // Only follows the social rules.
+	RoleInformed             // Follows the social rules and steers towards it's Goal.
+)
+
+// GoalKind selects what an informed Boid steers towards.
+type GoalKind int
+
+const (
+	GoalDirection GoalKind = iota // Steers in the direction of V.
+	GoalPoint                     // Steers towards the position V.
+)
+
+// Goal is what an informed Boid steers towards, with a preference Weight that decides how
+// strongly it steers towards it compared to the social rules.
+type Goal struct {
+	Kind   GoalKind
+	V      Vector
+	Weight float64
 }
 
-func (s *Swarm) updateBoid(b *Boid, dirty bool, target Vector) {
+// DirectionGoal returns a Goal for steering in a direction.
+func DirectionGoal(dir Vector, weight float64) Goal {
+	return Goal{GoalDirection, dir, weight}
+}
+
+// PointGoal returns a Goal for steering towards a position.
+func PointGoal(pos Vector, weight float64) Goal {
+	return Goal{GoalPoint, pos, weight}
+}
+
+// Steer returns the steering force towards the Goal, for a Boid at pos.
+func (g Goal) Steer(pos Vector) Vector {
+	dir := g.V
+	if g.Kind == GoalPoint {
+		dir = g.V.Subv(pos)
+	}
+	return dir.Normalize().Mul(g.Weight)
+}
+
+func (s *Swarm) updateBoid(w *worker, b *Boid, dirty bool, target Vector) {
 	if !dirty {
 		b.Pos = b.Pos.Addv(b.Vel.Round())
+		if len(s.regions) > 0 {
+			s.checkRegions(w, b)
+		}
 		return
 	}
 
@@ -25,21 +86,47 @@ func (s *Swarm) updateBoid(b *Boid, dirty bool, target Vector) {
 	coh := NewVector(0, 0)
 	ali := NewVector(0, 0)
 	sep := NewVector(0, 0)
+	loudest := 0.0
+	collisions := s.hooked(EventCollision)
 	s.Index.IterNeighbours(b, func(id int) {
 		n := s.Boids[id]
 		num += 1
 		coh = coh.Addv(n.Pos)
 		ali = ali.Addv(n.Vel)
 		sep = sep.Subv(s.separation(b, n))
+		if collisions && id > b.ID {
+			s.collision(w, b, n)
+		}
+		if n.Alarm > loudest && s.Conf.AlarmRange > 0 {
+			loudest = s.hearAlarm(b, n, loudest)
+		}
 	})
+	w.visited += uint64(num)
+	s.updateAlarm(b, loudest)
+	sep = sep.Mul(1 + s.Conf.AlarmSeparation*b.Alarm)
 
 	if num > 0 {
 		coh = s.cohesion(b, coh, num)
 		ali = s.alignment(b, ali, num)
 	}
-	tar := s.centerTarget(b, target)
-	b.Vel = b.Vel.Addv(coh).Addv(ali).Addv(sep).Addv(tar)
-	b.Vel = s.clampSpeed(b)
+	soc := coh.Addv(ali).Addv(sep).Mul(b.Traits.Social)
+	var tar Vector
+	switch {
+	case b.Role == RoleNaive:
+		tar = NewVector(0, 0)
+	case b.Role == RoleInformed:
+		tar = b.Goal.Steer(b.Pos)
+	case b.route != nil:
+		tar = s.centerTarget(b, b.route.Target())
+	default:
+		tar = s.centerTarget(b, target)
+	}
+	steer := soc.Addv(tar)
+	if s.Conf.MaxForce > 0 {
+		steer = steer.Limit(s.Conf.MaxForce)
+	}
+	vel := s.clampSpeed(b, b.Vel.Addv(steer))
+	b.next = s.turn(b, vel)
 }
 
 func (s *Swarm) cohesion(b *Boid, coh Vector, num float64) Vector {
@@ -59,6 +146,14 @@ func (s *Swarm) separation(b, n *Boid) Vector {
 	return NewVector(0, 0)
 }
 
+// collision fires an EventCollision if the Boids are too close.
+// Only called once per pair of Boids, as each pair is visited from both sides.
+func (s *Swarm) collision(w *worker, b, n *Boid) {
+	if n.Pos.DistanceSq(b.Pos) < s.squareCollisionRange {
+		w.emit(Event{Kind: EventCollision, Tick: s.tick, Boid: b.ID, Other: n.ID, Pos: b.Pos})
+	}
+}
+
 func (s *Swarm) centerTarget(b *Boid, target Vector) Vector {
 	diff := target.Subv(b.Pos)
 	dist := diff.InRange(s.squareTargetRange)
@@ -68,13 +163,64 @@ func (s *Swarm) centerTarget(b *Boid, target Vector) Vector {
 	return diff.Mul(s.Conf.TargetAttractFactor)
 }
 
-func (s *Swarm) clampSpeed(b *Boid) Vector {
-	l := b.Vel.Dot(b.Vel)
+func (s *Swarm) clampSpeed(b *Boid, vel Vector) Vector {
+	l := vel.Dot(vel)
+	f := b.Traits.Speed * (1 + s.Conf.AlarmSpeed*b.Alarm)
 	switch {
-	case l > s.squareVelocityMax:
-		return b.Vel.Mul(s.Conf.VelocityMax / math.Sqrt(l))
-	case l < s.squareVelocityMin:
-		return b.Vel.Mul(s.Conf.VelocityMin / math.Sqrt(l))
+	case l > s.squareVelocityMax*f*f:
+		return vel.Mul(s.Conf.VelocityMax * f / math.Sqrt(l))
+	case l < s.squareVelocityMin*f*f:
+		return vel.Mul(s.Conf.VelocityMin * f / math.Sqrt(l))
+	}
+	return vel
+}
+
+// turn rotates the Boid's heading towards the new velocity, limited by the max turn rate,
+// and then realigns the velocity with the new heading.
+func (s *Swarm) turn(b *Boid, vel Vector) Vector {
+	a := vel.Angle()
+	if s.Conf.MaxTurnRate <= 0 {
+		b.Heading = a
+		return vel
 	}
-	return b.Vel
+	// Shortest way around the circle, within -Pi and Pi
+	diff := math.Remainder(a-b.Heading, 2*math.Pi)
+	diff = math.Max(-s.Conf.MaxTurnRate, math.Min(s.Conf.MaxTurnRate, diff))
+	b.Heading = math.Remainder(b.Heading+diff, 2*math.Pi)
+	return FromAngle(b.Heading).Mul(vel.Length())
+}
+
+// Overlaps smaller than this (in pixels) are ignored, as the pushes would otherwise
+// take forever to converge in dense groups.
+const collisionSlop float64 = 0.01
+
+// collide sums up how far the Boid must move to stop overlapping with it's neighbours.
+// Each Boid of an overlapping pair is pushed half the overlap, away from the other.
+// It only reads the neighbours' positions and writes to the Boid's own push, so it's
+// safe to run in parallel. It returns the number of overlapping neighbours.
+func (s *Swarm) collide(b *Boid) int {
+	overlaps := 0
+	b.push = NewVector(0, 0)
+	r := s.Conf.BodyRadius * b.Traits.Size
+	s.Index.IterNeighbours(b, func(id int) {
+		n := s.Boids[id]
+		min := r + s.Conf.BodyRadius*n.Traits.Size
+		diff := b.Pos.Subv(n.Pos)
+		dist := diff.InRange((min - collisionSlop) * (min - collisionSlop))
+		switch {
+		case dist > 0:
+			diff = diff.Div(dist)
+		case diff.X == 0 && diff.Y == 0 && min > collisionSlop:
+			// Exactly on top of each other, so pick opposite directions for the pair
+			diff = NewVector(1, 0)
+			if b.ID > n.ID {
+				diff = NewVector(-1, 0)
+			}
+		default:
+			return
+		}
+		b.push = b.push.Addv(diff.Mul((min - dist) / 2))
+		overlaps++
+	})
+	return overlaps
 }
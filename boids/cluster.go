@@ -0,0 +1,222 @@
+package boids
+
+import (
+	"sort"
+)
+
+// Cluster is a separate school of Boids, connected to each other through their neighbours.
+type Cluster struct {
+	ID     int
+	Size   int
+	Center Vector // Center of mass.
+	Vel    Vector // Mean velocity.
+}
+
+// Clusters maps each Boid to the Cluster it's part of.
+type Clusters struct {
+	IDs      []int // Cluster ID for each Boid, indexed by Boid ID.
+	Clusters []Cluster
+}
+
+// Cluster returns the Cluster with the ID, or false if there's no such Cluster.
+func (c Clusters) Cluster(id int) (Cluster, bool) {
+	for _, cl := range c.Clusters {
+		if cl.ID == id {
+			return cl, true
+		}
+	}
+	return Cluster{}, false
+}
+
+// FindClusters groups all Boids into Clusters, where each Boid in a Cluster is closer
+// than dist to at least one other Boid in the same Cluster.
+// Neighbours are found using the Index, so dist shouldn't be larger than the IndexOffset
+// and the Index should have been updated (with a dirty Update) beforehand.
+// The Clusters are numbered in order of their lowest Boid ID.
+func (s *Swarm) FindClusters(dist float64) Clusters {
+	uf := newUnionFind(len(s.Boids))
+	sq := dist * dist
+	for _, b := range s.Boids {
+		s.Index.IterNeighbours(b, func(id int) {
+			if id < b.ID {
+				return // Pairs are visited twice, from both Boids
+			}
+			if s.Boids[id].Pos.DistanceSq(b.Pos) < sq {
+				uf.union(b.ID, id)
+			}
+		})
+	}
+
+	c := Clusters{IDs: make([]int, len(s.Boids))}
+	roots := make(map[int]int)
+	for _, b := range s.Boids {
+		r := uf.find(b.ID)
+		id, found := roots[r]
+		if !found {
+			id = len(c.Clusters)
+			roots[r] = id
+			c.Clusters = append(c.Clusters, Cluster{ID: id})
+		}
+		c.IDs[b.ID] = id
+		cl := &c.Clusters[id]
+		cl.Size++
+		cl.Center = cl.Center.Addv(b.Pos)
+		cl.Vel = cl.Vel.Addv(b.Vel)
+	}
+	for i := range c.Clusters {
+		cl := &c.Clusters[i]
+		cl.Center = cl.Center.Div(float64(cl.Size))
+		cl.Vel = cl.Vel.Div(float64(cl.Size))
+	}
+	return c
+}
+
+type unionFind []int
+
+func newUnionFind(size int) unionFind {
+	uf := make(unionFind, size)
+	for i := range uf {
+		uf[i] = i
+	}
+	return uf
+}
+
+func (uf unionFind) find(i int) int {
+	for uf[i] != i {
+		uf[i] = uf[uf[i]] // Path halving
+		i = uf[i]
+	}
+	return i
+}
+
+func (uf unionFind) union(a, b int) {
+	a, b = uf.find(a), uf.find(b)
+	if a < b {
+		uf[b] = a
+	} else if b < a {
+		uf[a] = b
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// ClusterEventKind is the kind of change detected by a ClusterTracker.
+type ClusterEventKind int
+
+const (
+	ClusterSplit ClusterEventKind = iota // One Cluster split up into several.
+	ClusterMerge                         // Several Clusters merged into one.
+)
+
+// ClusterEvent describes which Clusters was split or merged.
+type ClusterEvent struct {
+	Kind ClusterEventKind
+	From []int // Cluster IDs from the previous update.
+	To   []int // Cluster IDs from the current update.
+}
+
+// ClusterTracker follows Clusters between updates, keeping their IDs stable
+// and detecting when they split up or merge.
+type ClusterTracker struct {
+	Distance float64 // Max distance between neighbours, see FindClusters.
+	MinSize  int     // Min number of Boids that must move between Clusters to count as a split or merge.
+
+	prev Clusters
+	next int
+}
+
+// NewClusterTracker returns a new ClusterTracker.
+func NewClusterTracker(dist float64, minSize int) *ClusterTracker {
+	return &ClusterTracker{Distance: dist, MinSize: minSize}
+}
+
+// Update finds the current Clusters in the Swarm and matches them with the Clusters
+// from the last update, by the number of Boids they share.
+// The largest part of an old Cluster keeps it's ID, while any other parts gets new IDs.
+func (t *ClusterTracker) Update(s *Swarm) (Clusters, []ClusterEvent) {
+	c := s.FindClusters(t.Distance)
+	if len(t.prev.IDs) != len(c.IDs) {
+		// First update (or the Swarm changed), nothing to match against
+		t.next = len(c.Clusters)
+		t.prev = c
+		return c, nil
+	}
+
+	type pair struct{ old, cur, num int }
+	overlap := make(map[[2]int]int)
+	for id, cur := range c.IDs {
+		overlap[[2]int{t.prev.IDs[id], cur}]++
+	}
+	pairs := make([]pair, 0, len(overlap))
+	for k, num := range overlap {
+		pairs = append(pairs, pair{k[0], k[1], num})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		a, b := pairs[i], pairs[j]
+		if a.num != b.num {
+			return a.num > b.num
+		}
+		if a.old != b.old {
+			return a.old < b.old
+		}
+		return a.cur < b.cur
+	})
+
+	// Greedily hand out the old IDs to the largest overlaps first
+	ids := make(map[int]int)
+	used := make(map[int]bool)
+	for _, p := range pairs {
+		if _, found := ids[p.cur]; found || used[p.old] {
+			continue
+		}
+		ids[p.cur] = p.old
+		used[p.old] = true
+	}
+	for i := range c.Clusters {
+		if _, found := ids[i]; !found {
+			ids[i] = t.next
+			t.next++
+		}
+	}
+
+	splits := make(map[int][]int)
+	merges := make(map[int][]int)
+	for _, p := range pairs {
+		if p.num < t.MinSize {
+			continue
+		}
+		splits[p.old] = append(splits[p.old], ids[p.cur])
+		merges[ids[p.cur]] = append(merges[ids[p.cur]], p.old)
+	}
+	var events []ClusterEvent
+	for _, old := range sortedKeys(splits) {
+		if len(splits[old]) > 1 {
+			sort.Ints(splits[old])
+			events = append(events, ClusterEvent{ClusterSplit, []int{old}, splits[old]})
+		}
+	}
+	for _, cur := range sortedKeys(merges) {
+		if len(merges[cur]) > 1 {
+			sort.Ints(merges[cur])
+			events = append(events, ClusterEvent{ClusterMerge, merges[cur], []int{cur}})
+		}
+	}
+
+	for i := range c.IDs {
+		c.IDs[i] = ids[c.IDs[i]]
+	}
+	for i := range c.Clusters {
+		c.Clusters[i].ID = ids[c.Clusters[i].ID]
+	}
+	t.prev = c
+	return c, events
+}
+
+func sortedKeys(m map[int][]int) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
@@ -0,0 +1,73 @@
+package boids
+
+import (
+	"testing"
+)
+
+// Places the Boids in a row, 10 pixels apart, with a gap before each index in gaps.
+func placeRow(s *Swarm, gaps ...int) {
+	x := 0.0
+	for _, b := range s.Boids {
+		for _, g := range gaps {
+			if b.ID == g {
+				x += 100
+			}
+		}
+		b.Pos = NewVector(x, 0)
+		b.Vel = NewVector(1, 0)
+		x += 10
+	}
+	s.Index.Update(s.Boids)
+}
+
+func TestClusters(t *testing.T) {
+	s := mustNew(t, Conf{Boids: 10, Workers: 1, IndexOffset: 50})
+	placeRow(s, 4)
+	c := s.FindClusters(15)
+	if len(c.Clusters) != 2 {
+		t.Fatalf("got %d clusters, expected 2", len(c.Clusters))
+	}
+	for i, e := range []int{0, 0, 0, 0, 1, 1, 1, 1, 1, 1} {
+		if c.IDs[i] != e {
+			t.Errorf("got cluster %d for boid %d, expected %d", c.IDs[i], i, e)
+		}
+	}
+	cl, _ := c.Cluster(1)
+	if cl.Size != 6 {
+		t.Errorf("got cluster size %d, expected 6", cl.Size)
+	}
+	assertVector(t, cl.Center, 165, 0)
+	assertVector(t, cl.Vel, 1, 0)
+}
+
+func TestClusterTracker(t *testing.T) {
+	s := mustNew(t, Conf{Boids: 10, Workers: 1, IndexOffset: 50})
+	tr := NewClusterTracker(15, 2)
+	placeRow(s, 4)
+	if _, ev := tr.Update(s); len(ev) != 0 {
+		t.Fatalf("got events %v, expected none", ev)
+	}
+
+	// Split the largest cluster, which should keep the old ID for it's largest part
+	placeRow(s, 4, 8)
+	c, ev := tr.Update(s)
+	if len(ev) != 1 || ev[0].Kind != ClusterSplit {
+		t.Fatalf("got events %v, expected one split", ev)
+	}
+	if ev[0].From[0] != 1 || len(ev[0].To) != 2 || ev[0].To[0] != 1 || ev[0].To[1] != 2 {
+		t.Errorf("got split %v -> %v, expected [1] -> [1 2]", ev[0].From, ev[0].To)
+	}
+	if c.IDs[0] != 0 || c.IDs[4] != 1 || c.IDs[9] != 2 {
+		t.Errorf("got cluster IDs %v, expected stable IDs", c.IDs)
+	}
+
+	// And merge all of them again
+	placeRow(s)
+	c, ev = tr.Update(s)
+	if len(ev) != 1 || ev[0].Kind != ClusterMerge || len(ev[0].From) != 3 {
+		t.Fatalf("got events %v, expected one merge of 3 clusters", ev)
+	}
+	if len(c.Clusters) != 1 {
+		t.Errorf("got %d clusters, expected 1", len(c.Clusters))
+	}
+}
@@ -0,0 +1,250 @@
+package boids
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultConf returns a Conf with sensible defaults, for a school of fish
+// on a 1280x720 screen.
+func DefaultConf() Conf {
+	return Conf{
+		Spawn: [2]Vector{
+			NewVector(0, 0),
+			NewVector(1280, 720),
+		},
+		Seed:                0,
+		Boids:               500,
+		Workers:             10,
+		IndexOffset:         50,
+		CohesionFactor:      0.001,
+		AlignmentFactor:     0.05,
+		SeparationRange:     20,
+		SeparationFactor:    0.3,
+		TargetRange:         50,
+		TargetRepelFactor:   0.3,
+		TargetAttractFactor: 0.00004,
+		VelocityMax:         1,
+		VelocityMin:         0.5,
+	}
+}
+
+// ConfError lists all problems found by Conf.Validate.
+type ConfError []string
+
+func (e ConfError) Error() string {
+	return "invalid conf: " + strings.Join(e, "; ")
+}
+
+func (e *ConfError) add(format string, args ...interface{}) {
+	*e = append(*e, fmt.Sprintf(format, args...))
+}
+
+// Validate checks the Conf for any values that would crash or silently break a Swarm.
+// It returns a ConfError listing every problem, or nil if there's none.
+func (c Conf) Validate() error {
+	var e ConfError
+	if c.Boids < 1 {
+		e.add("Boids must be at least 1, got %d", c.Boids)
+	}
+	if c.Workers < 1 {
+		e.add("Workers must be at least 1, got %d", c.Workers)
+	}
+	if c.IndexOffset < 1 {
+		e.add("IndexOffset must be at least 1, got %d", c.IndexOffset)
+	}
+	if c.Spawn[0].X > c.Spawn[1].X || c.Spawn[0].Y > c.Spawn[1].Y {
+		e.add("Spawn min %s must not be larger than max %s", c.Spawn[0], c.Spawn[1])
+	}
+
+	positive := []struct {
+		name string
+		val  float64
+	}{
+		{"CohesionFactor", c.CohesionFactor},
+		{"AlignmentFactor", c.AlignmentFactor},
+		{"SeparationRange", c.SeparationRange},
+		{"SeparationFactor", c.SeparationFactor},
+		{"TargetRange", c.TargetRange},
+		{"TargetRepelFactor", c.TargetRepelFactor},
+		{"TargetAttractFactor", c.TargetAttractFactor},
+		{"VelocityMax", c.VelocityMax},
+		{"VelocityMin", c.VelocityMin},
+		{"MaxForce", c.MaxForce},
+		{"MaxTurnRate", c.MaxTurnRate},
+		{"AlarmRange", c.AlarmRange},
+		{"AlarmDelay", float64(c.AlarmDelay)},
+		{"AlarmSpeed", c.AlarmSpeed},
+		{"AlarmSeparation", c.AlarmSeparation},
+		{"CollisionRange", c.CollisionRange},
+		{"ClusterRange", c.ClusterRange},
+		{"ClusterMinSize", float64(c.ClusterMinSize)},
+		{"BodyRadius", c.BodyRadius},
+		{"CollisionIterations", float64(c.CollisionIterations)},
+	}
+	for _, p := range positive {
+		// Also catches NaN
+		if !(p.val >= 0) {
+			e.add("%s must not be negative, got %v", p.name, p.val)
+		}
+	}
+	if c.VelocityMin > c.VelocityMax {
+		e.add("VelocityMin (%v) must not be larger than VelocityMax (%v)", c.VelocityMin, c.VelocityMax)
+	}
+	if !(c.AlarmDecay >= 0 && c.AlarmDecay <= 1) {
+		e.add("AlarmDecay must be within 0-1, got %v", c.AlarmDecay)
+	}
+	if !(c.AlarmThreshold >= 0 && c.AlarmThreshold <= 1) {
+		e.add("AlarmThreshold must be within 0-1, got %v", c.AlarmThreshold)
+	}
+
+	// Neighbours further away than this won't be found in the Index
+	ranges := []struct {
+		name string
+		val  float64
+	}{
+		{"SeparationRange", c.SeparationRange},
+		{"AlarmRange", c.AlarmRange},
+		{"CollisionRange", c.CollisionRange},
+		{"ClusterRange", c.ClusterRange},
+	}
+	for _, r := range ranges {
+		if c.IndexOffset > 0 && r.val > float64(c.IndexOffset) {
+			e.add("%s (%v) must not be larger than IndexOffset (%d)", r.name, r.val, c.IndexOffset)
+		}
+	}
+
+	traits := []struct {
+		name string
+		d    Distribution
+	}{
+		{"SpeedTrait", c.SpeedTrait},
+		{"SizeTrait", c.SizeTrait},
+		{"SocialTrait", c.SocialTrait},
+	}
+	for _, t := range traits {
+		name, d := t.name, t.d
+		switch {
+		case d.Kind < DistNone || d.Kind > DistNormal:
+			e.add("%s has unknown distribution kind %d", name, d.Kind)
+		case d.Kind == DistUniform && d.A > d.B:
+			e.add("%s min (%v) must not be larger than max (%v)", name, d.A, d.B)
+		case d.Kind == DistNormal && d.B < 0:
+			e.add("%s standard deviation must not be negative, got %v", name, d.B)
+		}
+	}
+
+	if c.FixedPoint {
+		if c.MaxTurnRate > 0 {
+			e.add("MaxTurnRate is not supported with FixedPoint")
+		}
+		if c.AlarmRange > 0 {
+			e.add("AlarmRange is not supported with FixedPoint")
+		}
+		if c.BodyRadius > 0 {
+			e.add("BodyRadius is not supported with FixedPoint")
+		}
+		for _, t := range traits {
+			if t.d.Kind == DistNormal {
+				e.add("%s can't use a normal distribution with FixedPoint, as it's not sampled the same on all platforms", t.name)
+			}
+		}
+	}
+
+	if len(e) > 0 {
+		return e
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// SetConf changes the Conf of a running Swarm, for example after a config file has been changed.
+// The variables that decides the Swarm's layout (Spawn, Seed, Boids, Workers, FixedPoint and the
+// trait distributions) are kept as they are, while everything else (including IndexOffset) is
+// changed at the start of the next Update.
+// The resulting Conf is validated first and nothing is changed if it's invalid.
+// It's safe to call concurrently with Update.
+func (s *Swarm) SetConf(conf Conf) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.setPending(conf)
+}
+
+// Tune changes single variables of a running Swarm, for example:
+//
+//	s.Tune(func(c *Conf) { c.CohesionFactor = 0.002 })
+//
+// The Conf passed to f holds any changes still waiting to be applied, so several calls can be
+// made between Updates. Otherwise it works the same as SetConf.
+func (s *Swarm) Tune(f func(c *Conf)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.Conf
+	if s.pending != nil {
+		c = *s.pending
+	}
+	f(&c)
+	return s.setPending(c)
+}
+
+// CurrentConf returns the Conf including any changes still waiting to be applied.
+// Unlike reading Swarm.Conf, it's safe to call concurrently with Update.
+func (s *Swarm) CurrentConf() Conf {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending != nil {
+		return *s.pending
+	}
+	return s.Conf
+}
+
+func (s *Swarm) setPending(conf Conf) error {
+	c := s.Conf
+	conf.Spawn, conf.Seed, conf.Boids, conf.Workers, conf.FixedPoint = c.Spawn, c.Seed, c.Boids, c.Workers, c.FixedPoint
+	conf.SpeedTrait, conf.SizeTrait, conf.SocialTrait = c.SpeedTrait, c.SizeTrait, c.SocialTrait
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+	s.pending = &conf
+	return nil
+}
+
+// applyPending applies any changes from SetConf or Tune, between Updates.
+func (s *Swarm) applyPending() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending == nil {
+		return
+	}
+	conf := *s.pending
+	s.pending = nil
+	if s.recorder != nil {
+		s.recordConf(conf)
+	}
+	rebuild := conf.IndexOffset != s.Conf.IndexOffset
+	s.setConf(conf)
+	if rebuild {
+		s.Index = NewIndex(conf.IndexOffset)
+		s.Index.Update(s.Boids)
+	}
+	if s.tracker != nil {
+		s.tracker.Distance = conf.ClusterRange
+		if s.tracker.Distance <= 0 {
+			s.tracker.Distance = float64(conf.IndexOffset)
+		}
+		s.tracker.MinSize = conf.ClusterMinSize
+	}
+}
+
+// setConf sets the Conf and updates any values precalculated from it.
+func (s *Swarm) setConf(conf Conf) {
+	s.Conf = conf
+	s.fixed = newFixedConf(conf)
+	s.squareAlarmRange = conf.AlarmRange * conf.AlarmRange
+	s.squareCollisionRange = conf.CollisionRange * conf.CollisionRange
+	s.squareSeparationRange = conf.SeparationRange * conf.SeparationRange
+	s.squareTargetRange = conf.TargetRange * conf.TargetRange
+	s.squareVelocityMax = conf.VelocityMax * conf.VelocityMax
+	s.squareVelocityMin = conf.VelocityMin * conf.VelocityMin
+}
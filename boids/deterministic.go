@@ -0,0 +1,145 @@
+package boids
+
+import (
+	"github.com/lmas/akvarium/boids/fixed"
+)
+
+// fixedState mirrors a Boid's movement using fixed-point numbers, see Conf.FixedPoint.
+type fixedState struct {
+	pos, vel, next fixed.Vector
+	speed, social  fixed.Fixed
+}
+
+// fixedConf holds the movement variables converted to fixed-point numbers.
+type fixedConf struct {
+	cohesion, alignment, separation fixed.Fixed
+	repel, attract                  fixed.Fixed
+	maxForce, velMax, velMin        fixed.Fixed
+	sepRange, targetRange           fixed.Fixed // Squared ranges.
+}
+
+func newFixedConf(c Conf) fixedConf {
+	return fixedConf{
+		cohesion:    fixed.FromFloat(c.CohesionFactor),
+		alignment:   fixed.FromFloat(c.AlignmentFactor),
+		separation:  fixed.FromFloat(c.SeparationFactor),
+		repel:       fixed.FromFloat(c.TargetRepelFactor),
+		attract:     fixed.FromFloat(c.TargetAttractFactor),
+		maxForce:    fixed.FromFloat(c.MaxForce),
+		velMax:      fixed.FromFloat(c.VelocityMax),
+		velMin:      fixed.FromFloat(c.VelocityMin),
+		sepRange:    fixed.FromFloat(c.SeparationRange * c.SeparationRange),
+		targetRange: fixed.FromFloat(c.TargetRange * c.TargetRange),
+	}
+}
+
+func toFixed(v Vector) fixed.Vector {
+	return fixed.VectorFromFloat(v.X, v.Y)
+}
+
+func fromFixed(v fixed.Vector) Vector {
+	return Vector{v.X.Float(), v.Y.Float()}
+}
+
+// syncFixed copies the Boid's position, velocity and traits into it's fixed-point state.
+func (s *Swarm) syncFixed(b *Boid) {
+	b.fx = fixedState{
+		pos:    toFixed(b.Pos),
+		vel:    toFixed(b.Vel),
+		next:   toFixed(b.Vel),
+		speed:  fixed.FromFloat(b.Traits.Speed),
+		social: fixed.FromFloat(b.Traits.Social),
+	}
+	b.Pos, b.Vel = fromFixed(b.fx.pos), fromFixed(b.fx.vel)
+}
+
+// updateBoidFixed is the same as updateBoid, but using only integer math.
+// The float position and velocity are only set from the fixed-point state, for reading
+// and rendering, and the Index (that only uses the float positions) gives the same bins
+// on all architectures as the conversions are exact.
+// Turn rate limits, startle alarms and collision resolution are not supported.
+func (s *Swarm) updateBoidFixed(w *worker, b *Boid, dirty bool, target Vector) {
+	f := &b.fx
+	if !dirty {
+		f.pos = f.pos.Add(f.vel)
+		b.Pos = fromFixed(f.pos)
+		if len(s.regions) > 0 {
+			s.checkRegions(w, b)
+		}
+		return
+	}
+
+	var num fixed.Fixed
+	var coh, ali, sep fixed.Vector
+	collisions := s.hooked(EventCollision)
+	s.Index.IterNeighbours(b, func(id int) {
+		n := s.Boids[id]
+		w.visited++
+		num += fixed.One
+		coh = coh.Add(n.fx.pos)
+		ali = ali.Add(n.fx.vel)
+		diff := n.fx.pos.Sub(f.pos)
+		if d := diff.Dot(diff); d < s.fixed.sepRange {
+			if l := fixed.Sqrt(d); l > 0 {
+				sep = sep.Sub(diff.Div(l).Mul(s.fixed.separation))
+			}
+		}
+		if collisions && id > b.ID {
+			s.collision(w, b, n)
+		}
+	})
+
+	if num > 0 {
+		coh = coh.Div(num).Sub(f.pos).Mul(s.fixed.cohesion)
+		ali = ali.Div(num).Sub(f.vel).Mul(s.fixed.alignment)
+	}
+	soc := coh.Add(ali).Add(sep).Mul(f.social)
+	var tar fixed.Vector
+	switch {
+	case b.Role == RoleNaive:
+	case b.Role == RoleInformed:
+		tar = steerFixed(f, b.Goal)
+	case b.route != nil:
+		tar = s.centerTargetFixed(f, toFixed(b.route.Target()))
+	default:
+		tar = s.centerTargetFixed(f, toFixed(target))
+	}
+	steer := soc.Add(tar)
+	if s.fixed.maxForce > 0 {
+		steer = steer.Limit(s.fixed.maxForce)
+	}
+	f.next = s.clampSpeedFixed(f, f.vel.Add(steer))
+	b.next = fromFixed(f.next)
+	b.Heading = b.next.Angle()
+}
+
+// steerFixed is the same as Goal.Steer, but using only integer math.
+func steerFixed(f *fixedState, g Goal) fixed.Vector {
+	dir := toFixed(g.V)
+	if g.Kind == GoalPoint {
+		dir = dir.Sub(f.pos)
+	}
+	return dir.Normalize().Mul(fixed.FromFloat(g.Weight))
+}
+
+func (s *Swarm) centerTargetFixed(f *fixedState, target fixed.Vector) fixed.Vector {
+	diff := target.Sub(f.pos)
+	if d := diff.Dot(diff); d < s.fixed.targetRange {
+		if l := fixed.Sqrt(d); l > 0 {
+			return diff.Div(l).Mul(-s.fixed.repel)
+		}
+	}
+	return diff.Mul(s.fixed.attract)
+}
+
+func (s *Swarm) clampSpeedFixed(f *fixedState, vel fixed.Vector) fixed.Vector {
+	l := vel.Dot(vel)
+	max, min := s.fixed.velMax.Mul(f.speed), s.fixed.velMin.Mul(f.speed)
+	switch {
+	case l > max.Mul(max):
+		return vel.Mul(max).Div(fixed.Sqrt(l))
+	case l < min.Mul(min) && l > 0:
+		return vel.Mul(min).Div(fixed.Sqrt(l))
+	}
+	return vel
+}
@@ -0,0 +1,120 @@
+package boids
+
+import (
+	"hash/fnv"
+	"testing"
+
+	"github.com/lmas/akvarium/boids/fixed"
+)
+
+func fixedHash(s *Swarm) uint64 {
+	h := fnv.New64a()
+	for _, b := range s.Boids {
+		for _, f := range []int64{int64(b.fx.pos.X), int64(b.fx.pos.Y), int64(b.fx.vel.X), int64(b.fx.vel.Y)} {
+			for i := 0; i < 8; i++ {
+				h.Write([]byte{byte(f >> (8 * i))})
+			}
+		}
+	}
+	return h.Sum64()
+}
+
+func TestFixedPoint(t *testing.T) {
+	conf := Conf{
+		Boids:   100,
+		Workers: 4,
+		Spawn: [2]Vector{
+			NewVector(0, 0),
+			NewVector(200, 200),
+		},
+		IndexOffset:         50,
+		FixedPoint:          true,
+		CohesionFactor:      0.001,
+		AlignmentFactor:     0.05,
+		SeparationRange:     20,
+		SeparationFactor:    0.3,
+		TargetRange:         50,
+		TargetRepelFactor:   0.3,
+		TargetAttractFactor: 0.00004,
+		VelocityMax:         1,
+		VelocityMin:         0.5,
+		SpeedTrait:          Uniform(0.8, 1.2),
+	}
+	run := func() uint64 {
+		s := mustNew(t, conf)
+		target := NewVector(100, 100)
+		for i := 0; i < 400; i++ {
+			s.Update(i%2 == 0, target)
+		}
+		for _, b := range s.Boids {
+			if b.Pos != fromFixed(b.fx.pos) || b.Vel != fromFixed(b.fx.vel) {
+				t.Fatalf("got boid %d at %s %s, expected same state as fixed %s %s", b.ID, b.Pos, b.Vel, b.fx.pos, b.fx.vel)
+			}
+		}
+		return fixedHash(s)
+	}
+	h1, h2 := run(), run()
+	if h1 != h2 {
+		t.Fatalf("got hash %x, expected %x (same seed)", h2, h1)
+	}
+	// Recorded on amd64, any other platform must give the exact same result
+	const expected uint64 = 0x76c09050942209c8
+	if h1 != expected {
+		t.Errorf("got hash %#x, expected %#x", h1, expected)
+	}
+}
+
+func TestFixedPointRoute(t *testing.T) {
+	conf := Conf{
+		Boids:   20,
+		Workers: 2,
+		Spawn: [2]Vector{
+			NewVector(0, 0),
+			NewVector(20, 20),
+		},
+		IndexOffset:         50,
+		FixedPoint:          true,
+		CohesionFactor:      0.01,
+		AlignmentFactor:     0.05,
+		SeparationRange:     5,
+		SeparationFactor:    0.3,
+		TargetRange:         5,
+		TargetAttractFactor: 0.01,
+		VelocityMax:         2,
+		VelocityMin:         0.5,
+	}
+	run := func() (uint64, int) {
+		s := mustNew(t, conf)
+		r := NewRoute(RouteLoop, 30, NewVector(200, 0), NewVector(200, 200), NewVector(0, 200))
+		s.SetRoute(r)
+		laps := 0
+		for i := 0; i < 3000; i++ {
+			c := r.Current()
+			s.Update(i%2 == 0, NewVector(0, 0))
+			if c != r.Current() && r.Current() == 0 {
+				laps++
+			}
+		}
+		return fixedHash(s), laps
+	}
+	h1, laps := run()
+	if h2, _ := run(); h1 != h2 {
+		t.Fatalf("got hash %x, expected %x (same seed)", h2, h1)
+	}
+	if laps < 1 {
+		t.Errorf("got %d laps, expected the route to be looped", laps)
+	}
+	// Recorded on amd64, any other platform must give the exact same result
+	const expected uint64 = 0x943ab827b922c5cc
+	if h1 != expected {
+		t.Errorf("got hash %#x, expected %#x", h1, expected)
+	}
+
+	// The arrive radius is inclusive, same as for floats
+	r := NewRoute(RouteOnce, 30, NewVector(200, 0), NewVector(200, 200))
+	r.advanceFixed(fixed.VectorFromFloat(200, 29))
+	r.advanceFixed(fixed.VectorFromFloat(200, 170))
+	if r.Current() != 1 || !r.Done() {
+		t.Errorf("got waypoint %d and done %v, expected the route to be done", r.Current(), r.Done())
+	}
+}
@@ -0,0 +1,131 @@
+package boids
+
+// EventKind is the kind of interaction that happened during a Swarm update.
+type EventKind int
+
+const (
+	EventEnter     EventKind = iota // A Boid entered a region.
+	EventLeave                      // A Boid left a region.
+	EventCollision                  // Two Boids came closer than Conf.CollisionRange.
+	EventEaten                      // A Boid was eaten, see Swarm.Eat.
+	EventSplit                      // A cluster split up into several, see ClusterTracker.
+	EventMerge                      // Several clusters merged into one, see ClusterTracker.
+	numEvents
+)
+
+// Event describes an interaction.
+type Event struct {
+	Kind    EventKind
+	Tick    uint64       // The Swarm's tick when the event happened.
+	Boid    int          // ID of the Boid involved, or -1 for cluster events.
+	Other   int          // ID of the other Boid for collisions, or the region ID for enter/leave events.
+	Pos     Vector       // Position of the Boid.
+	Cluster ClusterEvent // Cluster changes, for split/merge events.
+}
+
+// Hook is a callback that gets notified about Events.
+type Hook func(Event)
+
+// MaxRegions is the max number of regions that can be watched for enter/leave events.
+const MaxRegions int = 64
+
+type region struct {
+	min, max Vector
+}
+
+// On registers a Hook for a kind of Event.
+// Events are collected by each worker during an Update and then delivered in order,
+// from the same goroutine that called Update, after all workers are done.
+// Hooks must therefore not call Update themselves.
+// On must not be called concurrently with Update.
+func (s *Swarm) On(kind EventKind, h Hook) {
+	if kind < 0 || kind >= numEvents {
+		return
+	}
+	s.hooks[kind] = append(s.hooks[kind], h)
+	if (kind == EventSplit || kind == EventMerge) && s.tracker == nil {
+		r := s.Conf.ClusterRange
+		if r <= 0 {
+			r = float64(s.Conf.IndexOffset)
+		}
+		s.tracker = NewClusterTracker(r, s.Conf.ClusterMinSize)
+	}
+}
+
+// AddRegion adds a bounding box that is watched for Boids entering or leaving it,
+// and returns the region's ID. It returns -1 if there's already MaxRegions regions.
+func (s *Swarm) AddRegion(min, max Vector) int {
+	if len(s.regions) >= MaxRegions {
+		return -1
+	}
+	s.regions = append(s.regions, region{min, max})
+	return len(s.regions) - 1
+}
+
+// Eat removes a Boid from the Swarm, by respawning it at a new random position
+// within Conf.Spawn, and fires an EventEaten.
+// It must not be called concurrently with Update.
+func (s *Swarm) Eat(id int) {
+	if id < 0 || id >= len(s.Boids) {
+		return
+	}
+	if s.recorder != nil {
+		s.recorder.write(recEat, int64(id))
+	}
+	b := s.Boids[id]
+	s.fire(Event{Kind: EventEaten, Tick: s.tick, Boid: id, Other: -1, Pos: b.Pos})
+	b.Pos = s.spawnPos()
+	b.Vel = NewVector(0, 0)
+	b.regions = 0
+	if s.Conf.FixedPoint {
+		s.syncFixed(b)
+	}
+}
+
+func (s *Swarm) hooked(kind EventKind) bool {
+	return len(s.hooks[kind]) > 0
+}
+
+func (s *Swarm) fire(e Event) {
+	for _, h := range s.hooks[e.Kind] {
+		h(e)
+	}
+}
+
+// checkRegions fires enter/leave events for any regions the Boid has crossed.
+func (s *Swarm) checkRegions(w *worker, b *Boid) {
+	for i, r := range s.regions {
+		bit := uint64(1) << uint(i)
+		inside := b.Pos.Within(r.min, r.max)
+		was := b.regions&bit != 0
+		switch {
+		case inside && !was:
+			b.regions |= bit
+			w.emit(Event{Kind: EventEnter, Tick: s.tick, Boid: b.ID, Other: i, Pos: b.Pos})
+		case !inside && was:
+			b.regions &^= bit
+			w.emit(Event{Kind: EventLeave, Tick: s.tick, Boid: b.ID, Other: i, Pos: b.Pos})
+		}
+	}
+}
+
+// deliver fires all events collected by the workers, followed by any cluster changes.
+func (s *Swarm) deliver(dirty bool) {
+	for _, w := range s.workers {
+		for _, e := range w.events {
+			s.fire(e)
+		}
+		w.events = w.events[:0]
+	}
+	if !dirty || s.tracker == nil {
+		return
+	}
+	_, events := s.tracker.Update(s)
+	for _, ce := range events {
+		kind := EventSplit
+		if ce.Kind == ClusterMerge {
+			kind = EventMerge
+		}
+		s.fire(Event{Kind: kind, Tick: s.tick, Boid: -1, Other: -1, Cluster: ce})
+	}
+}
@@ -0,0 +1,49 @@
+package boids
+
+import (
+	"testing"
+)
+
+func TestEvents(t *testing.T) {
+	s := mustNew(t, Conf{Boids: 4, Workers: 2, IndexOffset: 50, CollisionRange: 5, VelocityMax: 10})
+	var got []Event
+	hook := func(e Event) { got = append(got, e) }
+	for k := EventEnter; k < numEvents; k++ {
+		s.On(k, hook)
+	}
+	r := s.AddRegion(NewVector(10, -10), NewVector(20, 10))
+
+	// Boid 0 and 1 are about to collide, while 2 and 3 moves through the region
+	for i, p := range []Vector{{0, 0}, {3, 0}, {8, 0}, {200, 0}} {
+		s.Boids[i].Pos = p
+		s.Boids[i].Vel = NewVector(0, 0)
+	}
+	s.Boids[2].Vel = NewVector(5, 0)
+
+	run := func(dirty bool) []Event {
+		got = nil
+		s.Update(dirty, NewVector(0, 0))
+		return got
+	}
+
+	ev := run(true)
+	if len(ev) != 1 || ev[0].Kind != EventCollision || ev[0].Boid != 0 || ev[0].Other != 1 {
+		t.Fatalf("got events %+v, expected a collision between boid 0 and 1", ev)
+	}
+	ev = run(false)
+	if len(ev) != 1 || ev[0].Kind != EventEnter || ev[0].Boid != 2 || ev[0].Other != r || ev[0].Tick != 1 {
+		t.Fatalf("got events %+v, expected boid 2 entering region %d", ev, r)
+	}
+	ev = run(false)
+	ev = append(ev, run(false)...)
+	if len(ev) != 1 || ev[0].Kind != EventLeave || ev[0].Boid != 2 {
+		t.Fatalf("got events %+v, expected boid 2 leaving region %d", ev, r)
+	}
+
+	got = nil
+	s.Eat(3)
+	if len(got) != 1 || got[0].Kind != EventEaten || got[0].Boid != 3 {
+		t.Fatalf("got events %+v, expected boid 3 being eaten", got)
+	}
+	assertVector(t, got[0].Pos, 200, 0)
+}
@@ -0,0 +1,115 @@
+package boids
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ExportFormat is the file format written by an Exporter.
+type ExportFormat int
+
+const (
+	ExportCSV   ExportFormat = iota // Comma separated values, with a header row.
+	ExportJSONL                     // One JSON object per line.
+)
+
+// ParseExportFormat returns the format with the name "csv" or "jsonl".
+func ParseExportFormat(name string) (ExportFormat, error) {
+	switch strings.ToLower(name) {
+	case "csv":
+		return ExportCSV, nil
+	case "jsonl":
+		return ExportJSONL, nil
+	}
+	return 0, fmt.Errorf("unknown export format '%s', expected csv or jsonl", name)
+}
+
+// Exporter writes the trajectories of Boids, as rows of "tick,id,x,y,vx,vy", for offline analysis.
+type Exporter struct {
+	Format ExportFormat
+	Every  uint64 // Only export every n:th tick, 0 exports all ticks.
+
+	w      *bufio.Writer
+	region *region
+	header bool
+	buf    []byte
+}
+
+// NewExporter returns an Exporter writing to w.
+func NewExporter(w io.Writer, format ExportFormat) *Exporter {
+	return &Exporter{
+		Format: format,
+		w:      bufio.NewWriter(w),
+	}
+}
+
+// SetRegion limits the export to Boids within a bounding box.
+func (e *Exporter) SetRegion(min, max Vector) {
+	e.region = &region{min, max}
+}
+
+// Write exports the Boids of the Swarm at it's current tick, unless the tick is skipped
+// by Every. It should be called after each Update.
+// It must not be called concurrently with Update.
+func (e *Exporter) Write(s *Swarm) error {
+	if e.Every > 1 && s.tick%e.Every != 0 {
+		return nil
+	}
+	if e.Format == ExportCSV && !e.header {
+		e.header = true
+		if _, err := e.w.WriteString("tick,id,x,y,vx,vy\n"); err != nil {
+			return err
+		}
+	}
+	for _, b := range s.Boids {
+		if e.region != nil && !b.Pos.Within(e.region.min, e.region.max) {
+			continue
+		}
+		if _, err := e.w.Write(e.row(s.tick, b)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes any buffered rows to the underlying writer.
+func (e *Exporter) Flush() error {
+	return e.w.Flush()
+}
+
+// row formats a single row, reusing the same buffer to avoid allocations.
+func (e *Exporter) row(tick uint64, b *Boid) []byte {
+	buf := e.buf[:0]
+	num := func(name string, f float64) {
+		if e.Format == ExportJSONL {
+			buf = append(buf, `,"`...)
+			buf = append(buf, name...)
+			buf = append(buf, `":`...)
+		} else {
+			buf = append(buf, ',')
+		}
+		buf = strconv.AppendFloat(buf, f, 'g', -1, 64)
+	}
+	if e.Format == ExportJSONL {
+		buf = append(buf, `{"tick":`...)
+		buf = strconv.AppendUint(buf, tick, 10)
+		buf = append(buf, `,"id":`...)
+	} else {
+		buf = strconv.AppendUint(buf, tick, 10)
+		buf = append(buf, ',')
+	}
+	buf = strconv.AppendInt(buf, int64(b.ID), 10)
+	num("x", b.Pos.X)
+	num("y", b.Pos.Y)
+	num("vx", b.Vel.X)
+	num("vy", b.Vel.Y)
+	if e.Format == ExportJSONL {
+		buf = append(buf, '}')
+	}
+	buf = append(buf, '\n')
+	e.buf = buf
+	return buf
+}
@@ -0,0 +1,66 @@
+package boids
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestExporter(t *testing.T) {
+	s := mustNew(t, Conf{
+		Boids:       3,
+		Workers:     1,
+		IndexOffset: 50,
+	})
+	for i, b := range s.Boids {
+		b.Pos = NewVector(float64(i*10), 0.5)
+		b.Vel = NewVector(1, -0.25)
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		var buf bytes.Buffer
+		e := NewExporter(&buf, ExportCSV)
+		e.SetRegion(NewVector(5, 0), NewVector(100, 100))
+		if err := e.Write(s); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		expected := "tick,id,x,y,vx,vy\n0,1,10,0.5,1,-0.25\n0,2,20,0.5,1,-0.25\n"
+		if buf.String() != expected {
+			t.Errorf("got %q, expected %q", buf.String(), expected)
+		}
+	})
+	t.Run("jsonl", func(t *testing.T) {
+		var buf bytes.Buffer
+		e := NewExporter(&buf, ExportJSONL)
+		e.Every = 2
+		for i := 0; i < 4; i++ {
+			if err := e.Write(s); err != nil {
+				t.Fatal(err)
+			}
+			s.tick++
+		}
+		if err := e.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 6 {
+			t.Fatalf("got %d rows, expected 6", len(lines))
+		}
+		var row struct {
+			Tick   uint64
+			ID     int
+			X, Y   float64
+			VX, VY float64
+		}
+		if err := json.Unmarshal([]byte(lines[5]), &row); err != nil {
+			t.Fatal(err)
+		}
+		if row.Tick != 2 || row.ID != 2 || row.X != 20 || row.VY != -0.25 {
+			t.Errorf("got row %+v, expected tick 2 for boid 2", row)
+		}
+	})
+}
@@ -0,0 +1,177 @@
+// Package fixed implements deterministic fixed-point math, using only integer operations.
+// Floating point results can differ between architectures and compilers (for example when
+// multiplications and additions are fused into FMA instructions), while integer math gives
+// the exact same results everywhere.
+package fixed
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// Fixed is a signed fixed-point number, with 31 integer bits and 32 fractional bits.
+// Operations that overflows are saturated to Max or Min.
+type Fixed int64
+
+// Frac is the number of fractional bits.
+const Frac = 32
+
+const (
+	One Fixed = 1 << Frac
+	Max Fixed = math.MaxInt64
+	Min Fixed = -math.MaxInt64
+)
+
+// FromFloat converts a float, rounded to the nearest fixed-point number.
+func FromFloat(f float64) Fixed {
+	f = math.Round(f * float64(One))
+	switch {
+	case f >= float64(Max):
+		return Max
+	case f <= float64(Min):
+		return Min
+	}
+	return Fixed(f)
+}
+
+// FromInt converts an integer.
+func FromInt(i int) Fixed {
+	return Fixed(i) << Frac
+}
+
+// Float converts to a float.
+func (f Fixed) Float() float64 {
+	return float64(f) / float64(One)
+}
+
+func (f Fixed) String() string {
+	return fmt.Sprintf("%0.6f", f.Float())
+}
+
+// abs returns the absolute value, and true if it was negative.
+func (f Fixed) abs() (uint64, bool) {
+	if f < 0 {
+		return uint64(-f), true
+	}
+	return uint64(f), false
+}
+
+func saturate(u uint64, neg bool) Fixed {
+	if u > uint64(Max) {
+		u = uint64(Max)
+	}
+	if neg {
+		return -Fixed(u)
+	}
+	return Fixed(u)
+}
+
+// Mul multiplies two numbers, truncating the result towards zero.
+func (f Fixed) Mul(o Fixed) Fixed {
+	a, na := f.abs()
+	b, nb := o.abs()
+	hi, lo := bits.Mul64(a, b)
+	if hi>>Frac != 0 {
+		return saturate(math.MaxUint64, na != nb)
+	}
+	return saturate(hi<<(64-Frac)|lo>>Frac, na != nb)
+}
+
+// Div divides two numbers, truncating the result towards zero.
+// Dividing by zero saturates the result, similar to an infinite float.
+func (f Fixed) Div(o Fixed) Fixed {
+	a, na := f.abs()
+	b, nb := o.abs()
+	hi, lo := a>>(64-Frac), a<<Frac
+	if b == 0 || hi >= b {
+		return saturate(math.MaxUint64, na != nb)
+	}
+	q, _ := bits.Div64(hi, lo, b)
+	return saturate(q, na != nb)
+}
+
+// Sqrt returns the square root, rounded down, or 0 for negative numbers.
+// It's calculated bit by bit, using integers only.
+func Sqrt(f Fixed) Fixed {
+	if f <= 0 {
+		return 0
+	}
+	// The square root of the raw value needs to be shifted back by half of the
+	// fractional bits, so shift the raw value up by Frac bits first (into 128 bits).
+	u := uint64(f)
+	hi, lo := u>>(64-Frac), u<<Frac
+	var r uint64
+	for bit := uint64(1) << 47; bit > 0; bit >>= 1 {
+		t := r | bit
+		th, tl := bits.Mul64(t, t)
+		if th < hi || (th == hi && tl <= lo) {
+			r = t
+		}
+	}
+	return Fixed(r)
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Vector is a 2D vector using fixed-point numbers.
+type Vector struct {
+	X, Y Fixed
+}
+
+func NewVector(x, y Fixed) Vector {
+	return Vector{x, y}
+}
+
+// VectorFromFloat converts two floats into a Vector.
+func VectorFromFloat(x, y float64) Vector {
+	return Vector{FromFloat(x), FromFloat(y)}
+}
+
+func (v Vector) String() string {
+	return fmt.Sprintf("(%s, %s)", v.X, v.Y)
+}
+
+func (v Vector) Add(other Vector) Vector {
+	return Vector{v.X + other.X, v.Y + other.Y}
+}
+
+func (v Vector) Sub(other Vector) Vector {
+	return Vector{v.X - other.X, v.Y - other.Y}
+}
+
+func (v Vector) Mul(f Fixed) Vector {
+	return Vector{v.X.Mul(f), v.Y.Mul(f)}
+}
+
+func (v Vector) Div(f Fixed) Vector {
+	return Vector{v.X.Div(f), v.Y.Div(f)}
+}
+
+// Dot calculates the dot product of two vectors.
+func (v Vector) Dot(other Vector) Fixed {
+	return v.X.Mul(other.X) + v.Y.Mul(other.Y)
+}
+
+// Length calculates the vector length/magnitude.
+func (v Vector) Length() Fixed {
+	return Sqrt(v.Dot(v))
+}
+
+// Normalize returns a unit vector with the same direction, or a zero vector if it has no length.
+func (v Vector) Normalize() Vector {
+	l := v.Length()
+	if l == 0 {
+		return v
+	}
+	return v.Div(l)
+}
+
+// Limit limits the vector length to max, while keeping it's direction.
+func (v Vector) Limit(max Fixed) Vector {
+	l := v.Dot(v)
+	if l > max.Mul(max) {
+		return v.Mul(max).Div(Sqrt(l))
+	}
+	return v
+}
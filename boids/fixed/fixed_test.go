@@ -0,0 +1,56 @@
+package fixed
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFixed(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Fixed
+		e    float64
+	}{
+		{"from float", FromFloat(1.5), 1.5},
+		{"from int", FromInt(-3), -3},
+		{"mul", FromFloat(1.5).Mul(FromFloat(-2.25)), -3.375},
+		{"mul small", FromFloat(0.00004).Mul(FromInt(1000)), 0.04},
+		{"div", FromFloat(-3.375).Div(FromFloat(1.5)), -2.25},
+		{"div by zero", FromInt(1).Div(0), Max.Float()},
+		{"mul overflow", FromInt(1 << 20).Mul(FromInt(-1 << 20)), Min.Float()},
+		{"sqrt", Sqrt(FromInt(2)), math.Sqrt2},
+		{"sqrt small", Sqrt(FromFloat(0.0001)), 0.01},
+		{"sqrt large", Sqrt(FromInt(1 << 30)), 1 << 15},
+		{"sqrt negative", Sqrt(FromInt(-4)), 0},
+		{"length", VectorFromFloat(3, 4).Length(), 5},
+		{"dot", VectorFromFloat(1, 2).Dot(VectorFromFloat(3, 4)), 11},
+	}
+	for _, tt := range tests {
+		if math.Abs(tt.got.Float()-tt.e) > 1e-6 {
+			t.Errorf("%s: got %s, expected %v", tt.name, tt.got, tt.e)
+		}
+	}
+}
+
+func TestSqrtExact(t *testing.T) {
+	// The result must be the largest number that squared doesn't exceed the input
+	for _, f := range []Fixed{1, 2, 3, One - 1, One, One + 1, FromFloat(12345.6789), Max} {
+		r := Sqrt(f)
+		if r.Mul(r) > f || (r+1).Mul(r+1) < f {
+			t.Errorf("got sqrt(%d) = %d, expected it rounded down", f, r)
+		}
+	}
+}
+
+func TestVector(t *testing.T) {
+	v := VectorFromFloat(3, 4)
+	if got := v.Normalize(); math.Abs(got.X.Float()-0.6) > 1e-8 || math.Abs(got.Y.Float()-0.8) > 1e-8 {
+		t.Errorf("got normalized vector %s, expected (0.6, 0.8)", got)
+	}
+	if got := v.Limit(FromFloat(2.5)).Length().Float(); math.Abs(got-2.5) > 1e-8 {
+		t.Errorf("got limited length %f, expected 2.5", got)
+	}
+	if got := v.Add(v).Sub(v).Mul(FromInt(2)).Div(FromInt(2)); got != v {
+		t.Errorf("got vector %s, expected %s", got, v)
+	}
+}
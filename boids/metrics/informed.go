@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"math"
+
+	"github.com/lmas/akvarium/boids"
+)
+
+// InformedResult is the outcome of running a Swarm with a fraction of informed Boids.
+type InformedResult struct {
+	Fraction     float64
+	Informed     int     // Number of informed Boids.
+	Accuracy     float64 // Mean cosine of the angle between the group's and the goal's direction, 1 is perfect.
+	Polarization float64 // Mean polarization at the end of the runs.
+}
+
+// InformedExperiment measures how well a group follows a goal, depending on the fraction of
+// informed Boids (see boids.RoleInformed), while the rest are naive. For a point goal, the goal's
+// direction is the one from the group's center at the end of each run.
+// Each fraction is run headless for a number of steps, repeated trials times with different seeds.
+func InformedExperiment(conf boids.Conf, goal boids.Goal, steps, trials int, fractions ...float64) ([]InformedResult, error) {
+	results := make([]InformedResult, len(fractions))
+	for i, f := range fractions {
+		r := &results[i]
+		r.Fraction = f
+		r.Informed = int(math.Round(f * float64(conf.Boids)))
+		for t := 0; t < trials; t++ {
+			c := conf
+			c.Seed = conf.Seed + int64(t)
+			s, err := boids.New(c)
+			if err != nil {
+				return nil, err
+			}
+			ids := make([]int, r.Informed)
+			for j := range ids {
+				ids[j] = j
+			}
+			s.Inform(goal, ids...)
+			for j := 0; j < steps; j++ {
+				s.Update(j%2 == 0, boids.NewVector(0, 0))
+			}
+			dir := goal.V
+			if goal.Kind == boids.GoalPoint {
+				dir = goal.V.Subv(Center(s.Boids))
+			}
+			r.Accuracy += Direction(s.Boids).Dot(dir.Normalize())
+			r.Polarization += Polarization(s.Boids)
+			s.Close()
+		}
+		if trials > 0 {
+			r.Accuracy /= float64(trials)
+			r.Polarization /= float64(trials)
+		}
+	}
+	return results, nil
+}
+
+// Direction returns the group's direction of travel, as a unit vector.
+func Direction(group []*boids.Boid) boids.Vector {
+	sum := boids.NewVector(0, 0)
+	for _, b := range group {
+		sum = sum.Addv(b.Vel.Normalize())
+	}
+	return sum.Normalize()
+}
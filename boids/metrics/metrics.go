@@ -0,0 +1,165 @@
+// Package metrics calculates common observables of collective behaviour for a Swarm.
+// It allows for comparing different parameter sets objectively, instead of eyeballing the simulation.
+package metrics
+
+import (
+	"math"
+
+	"github.com/lmas/akvarium/boids"
+)
+
+// Speed summarises the distribution of the Boids' speeds.
+type Speed struct {
+	Mean, StdDev, Min, Max float64
+}
+
+// Metrics is a snapshot of observables for a whole Swarm.
+type Metrics struct {
+	Boids            int
+	Center           boids.Vector // Center of mass of the group.
+	Polarization     float64      // 0 when moving in random directions, 1 when all Boids move the same way.
+	Milling          float64      // 0 for no rotation, 1 when all Boids circle around the center.
+	NearestNeighbour float64      // Mean distance to the closest neighbour.
+	Radius           float64      // Mean distance to the center of mass.
+	Density          float64      // Number of Boids per square pixel, within the group radius.
+	Speed            Speed
+}
+
+// Compute calculates all Metrics for a Swarm.
+// The Swarm's Index is used for finding neighbours, so it should have been updated
+// (with a dirty Swarm.Update) beforehand.
+func Compute(s *boids.Swarm) Metrics {
+	c := Center(s.Boids)
+	r := Radius(s.Boids, c)
+	return Metrics{
+		Boids:            len(s.Boids),
+		Center:           c,
+		Polarization:     Polarization(s.Boids),
+		Milling:          Milling(s.Boids, c),
+		NearestNeighbour: NearestNeighbour(s),
+		Radius:           r,
+		Density:          Density(len(s.Boids), r),
+		Speed:            SpeedStats(s.Boids),
+	}
+}
+
+// Center returns the center of mass for a group of Boids.
+func Center(group []*boids.Boid) boids.Vector {
+	c := boids.NewVector(0, 0)
+	if len(group) < 1 {
+		return c
+	}
+	for _, b := range group {
+		c = c.Addv(b.Pos)
+	}
+	return c.Div(float64(len(group)))
+}
+
+// Polarization is the length of the average unit velocity, also called the group's order parameter.
+func Polarization(group []*boids.Boid) float64 {
+	sum, num := boids.NewVector(0, 0), 0.0
+	for _, b := range group {
+		l := b.Vel.Length()
+		if l == 0 {
+			continue
+		}
+		sum = sum.Addv(b.Vel.Div(l))
+		num++
+	}
+	if num == 0 {
+		return 0
+	}
+	return sum.Length() / num
+}
+
+// Milling is the normalised angular momentum of the group around the center c.
+func Milling(group []*boids.Boid, c boids.Vector) float64 {
+	sum, num := 0.0, 0.0
+	for _, b := range group {
+		r := b.Pos.Subv(c)
+		l := r.Length() * b.Vel.Length()
+		if l == 0 {
+			continue
+		}
+		// The z component of the angular momentum
+		sum += r.Cross(b.Vel) / l
+		num++
+	}
+	if num == 0 {
+		return 0
+	}
+	return math.Abs(sum) / num
+}
+
+// NearestNeighbour returns the mean distance between each Boid and it's closest neighbour.
+// Neighbours are first looked up in the neighbouring bins of an Index, which only covers a range of
+// IndexOffset around each Boid. All Boids are checked when the closest one found is farther than
+// that (or when none was found), so the distance is always exact.
+func NearestNeighbour(s *boids.Swarm) float64 {
+	if len(s.Boids) < 2 {
+		return 0
+	}
+	// The Swarm's own Index is built before the positions are updated, so it can be out of date
+	idx := boids.NewIndex(s.Conf.IndexOffset)
+	idx.Update(s.Boids)
+	limit := float64(s.Conf.IndexOffset * s.Conf.IndexOffset)
+	sum := 0.0
+	for _, b := range s.Boids {
+		min := math.Inf(1)
+		closest := func(n *boids.Boid) {
+			min = math.Min(min, n.Pos.DistanceSq(b.Pos))
+		}
+		idx.IterNeighbours(b, func(id int) {
+			closest(s.Boids[id])
+		})
+		if min > limit {
+			for _, n := range s.Boids {
+				if n.ID != b.ID {
+					closest(n)
+				}
+			}
+		}
+		sum += math.Sqrt(min)
+	}
+	return sum / float64(len(s.Boids))
+}
+
+// Radius returns the mean distance between the Boids and the center c.
+func Radius(group []*boids.Boid, c boids.Vector) float64 {
+	if len(group) < 1 {
+		return 0
+	}
+	sum := 0.0
+	for _, b := range group {
+		sum += b.Pos.Subv(c).Length()
+	}
+	return sum / float64(len(group))
+}
+
+// Density returns the number of Boids per square pixel, for a circle with the group's radius.
+func Density(num int, radius float64) float64 {
+	if radius == 0 {
+		return 0
+	}
+	return float64(num) / (math.Pi * radius * radius)
+}
+
+// SpeedStats returns the mean, standard deviation and min/max of the Boids' speeds.
+func SpeedStats(group []*boids.Boid) Speed {
+	if len(group) < 1 {
+		return Speed{}
+	}
+	sp := Speed{Min: math.Inf(1), Max: math.Inf(-1)}
+	sum, sq := 0.0, 0.0
+	for _, b := range group {
+		l := b.Vel.Length()
+		sum += l
+		sq += l * l
+		sp.Min = math.Min(sp.Min, l)
+		sp.Max = math.Max(sp.Max, l)
+	}
+	n := float64(len(group))
+	sp.Mean = sum / n
+	sp.StdDev = math.Sqrt(math.Max(0, sq/n-sp.Mean*sp.Mean))
+	return sp
+}
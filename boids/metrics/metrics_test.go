@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/lmas/akvarium/boids"
+)
+
+func newSwarm(num int) *boids.Swarm {
+	s, err := boids.New(boids.Conf{
+		Boids:       num,
+		Workers:     1,
+		IndexOffset: 50,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Places the Boids evenly on a circle, with velocities along the tangent.
+func circle(s *boids.Swarm, radius float64) {
+	for i, b := range s.Boids {
+		a := 2 * math.Pi * float64(i) / float64(len(s.Boids))
+		b.Pos = boids.FromAngle(a).Mul(radius)
+		b.Vel = boids.FromAngle(a + math.Pi/2)
+	}
+	s.Index.Update(s.Boids)
+}
+
+func assertFloat(t *testing.T, name string, f, e float64) {
+	if math.Abs(f-e) > 1e-6 {
+		t.Errorf("got %s %f, expected %f", name, f, e)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	t.Run("aligned", func(t *testing.T) {
+		s := newSwarm(10)
+		for i, b := range s.Boids {
+			b.Pos = boids.NewVector(float64(i)*10, 0)
+			b.Vel = boids.NewVector(0, float64(i+1))
+		}
+		s.Index.Update(s.Boids)
+		m := Compute(s)
+		assertFloat(t, "polarization", m.Polarization, 1)
+		assertFloat(t, "nearest neighbour", m.NearestNeighbour, 10)
+		assertFloat(t, "mean speed", m.Speed.Mean, 5.5)
+		assertFloat(t, "min speed", m.Speed.Min, 1)
+		assertFloat(t, "max speed", m.Speed.Max, 10)
+		assertFloat(t, "center x", m.Center.X, 45)
+	})
+	t.Run("milling", func(t *testing.T) {
+		s := newSwarm(100)
+		circle(s, 100)
+		m := Compute(s)
+		assertFloat(t, "polarization", m.Polarization, 0)
+		assertFloat(t, "milling", m.Milling, 1)
+		assertFloat(t, "radius", m.Radius, 100)
+		assertFloat(t, "density", m.Density, 100/(math.Pi*100*100))
+		assertFloat(t, "speed stddev", m.Speed.StdDev, 0)
+	})
+	t.Run("isolated", func(t *testing.T) {
+		s := newSwarm(2)
+		s.Boids[0].Pos = boids.NewVector(0, 0)
+		s.Boids[1].Pos = boids.NewVector(300, 400)
+		s.Index.Update(s.Boids)
+		assertFloat(t, "nearest neighbour", NearestNeighbour(s), 500)
+	})
+	t.Run("beyond index", func(t *testing.T) {
+		// The first Boid has the second in it's neighbouring bins, while the closest is two bins away
+		s := newSwarm(3)
+		s.Boids[0].Pos = boids.NewVector(49, 0)
+		s.Boids[1].Pos = boids.NewVector(-49, 99)
+		s.Boids[2].Pos = boids.NewVector(101, 0)
+		s.Index.Update(s.Boids)
+		assertFloat(t, "nearest neighbour", NearestNeighbour(s), (52+52+math.Hypot(98, 99))/3)
+	})
+}
+
+func TestInformedExperiment(t *testing.T) {
+	conf := boids.Conf{
+		Boids:   50,
+		Workers: 5,
+		Spawn: [2]boids.Vector{
+			boids.NewVector(0, 0),
+			boids.NewVector(100, 100),
+		},
+		IndexOffset:      50,
+		CohesionFactor:   0.01,
+		AlignmentFactor:  0.1,
+		SeparationRange:  10,
+		SeparationFactor: 0.3,
+		VelocityMax:      1,
+		VelocityMin:      0.5,
+	}
+	res, err := InformedExperiment(conf, boids.DirectionGoal(boids.NewVector(0, 1), 0.05), 600, 3, 0, 0.2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res[1].Informed != 10 {
+		t.Errorf("got %d informed boids, expected 10", res[1].Informed)
+	}
+	if res[1].Accuracy < 0.9 || res[1].Accuracy <= res[0].Accuracy {
+		t.Errorf("got accuracy %f (informed) and %f (naive), expected informed > 0.9 and better than naive",
+			res[1].Accuracy, res[0].Accuracy)
+	}
+}
@@ -0,0 +1,117 @@
+package boids
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Preset is a named Conf, tuned for a certain kind of flocking behaviour.
+type Preset struct {
+	Name        string
+	Description string
+	Conf        Conf
+}
+
+// Presets lists all the tuned Confs, selectable by name with PresetConf.
+// They all spawn inside the same 1280x720 screen as DefaultConf.
+var Presets = []Preset{
+	{"school", "A relaxed school of fish, slowly roaming around the target.", schoolConf()},
+	{"murmuration", "A fast, tightly aligned flock of starlings, sweeping back and forth as one.", murmurationConf()},
+	{"insects", "A loose and unaligned swarm of insects, buzzing around the target.", insectsConf()},
+	{"baitball", "A dense ball of bait fish, milling in circles around the target.", baitBallConf()},
+}
+
+// PresetConf returns the Conf of a named Preset.
+func PresetConf(name string) (Conf, error) {
+	names := make([]string, len(Presets))
+	for i, p := range Presets {
+		if p.Name == name {
+			return p.Conf, nil
+		}
+		names[i] = p.Name
+	}
+	return Conf{}, fmt.Errorf("unknown preset '%s', expected one of: %s", name, strings.Join(names, ", "))
+}
+
+// The same values as used by the main simulation.
+func schoolConf() Conf {
+	c := DefaultConf()
+	c.MaxForce = 0.1
+	c.MaxTurnRate = 0.2
+	c.BodyRadius = 5
+	c.CollisionIterations = 4
+	c.AlarmRange = 30
+	c.AlarmDelay = 1
+	c.AlarmDecay = 0.15
+	c.AlarmThreshold = 0.3
+	c.AlarmSpeed = 1.5
+	c.AlarmSeparation = 2
+	c.SpeedTrait = Uniform(0.8, 1.2)
+	c.SizeTrait = Normal(1, 0.1)
+	c.SocialTrait = Uniform(0.9, 1.1)
+	return c
+}
+
+// Strong alignment over a large neighbourhood (IndexOffset) makes the whole flock turn as one,
+// while the weak attraction lets it sweep far past the target before turning back.
+func murmurationConf() Conf {
+	c := DefaultConf()
+	c.Boids = 800
+	c.IndexOffset = 80
+	c.CohesionFactor = 0.002
+	c.AlignmentFactor = 0.5
+	c.SeparationRange = 12
+	c.SeparationFactor = 0.2
+	c.TargetRange = 0
+	c.TargetAttractFactor = 0.00005
+	c.VelocityMax = 4
+	c.VelocityMin = 3
+	c.MaxForce = 0.4
+	c.MaxTurnRate = 0.15
+	c.SpeedTrait = Uniform(0.95, 1.05)
+	c.SizeTrait = Normal(0.6, 0.05)
+	return c
+}
+
+// No alignment and strong separation keeps the insects from ever agreeing on a direction,
+// while the strong attraction keeps them close to the target.
+func insectsConf() Conf {
+	c := DefaultConf()
+	c.Boids = 300
+	c.CohesionFactor = 0.01
+	c.AlignmentFactor = 0
+	c.SeparationRange = 15
+	c.SeparationFactor = 1
+	c.TargetRange = 20
+	c.TargetRepelFactor = 0.5
+	c.TargetAttractFactor = 0.001
+	c.VelocityMax = 3
+	c.VelocityMin = 1
+	c.SpeedTrait = Uniform(0.5, 1.5)
+	c.SizeTrait = Normal(0.4, 0.05)
+	c.SocialTrait = Uniform(0.5, 1.5)
+	return c
+}
+
+// The small IndexOffset limits alignment to the closest neighbours, so the fish can't agree
+// on a single direction and instead ends up circling around the strongly attracting target.
+// The repelling target keeps the center of the ball empty.
+func baitBallConf() Conf {
+	c := DefaultConf()
+	c.Boids = 400
+	c.IndexOffset = 20
+	c.CohesionFactor = 0.002
+	c.AlignmentFactor = 0.1
+	c.SeparationRange = 12
+	c.SeparationFactor = 0.5
+	c.TargetRange = 50
+	c.TargetRepelFactor = 0.5
+	c.TargetAttractFactor = 0.002
+	c.VelocityMax = 2
+	c.VelocityMin = 1.5
+	c.MaxForce = 0.2
+	c.BodyRadius = 4
+	c.CollisionIterations = 4
+	c.SizeTrait = Normal(0.8, 0.05)
+	return c
+}
@@ -0,0 +1,71 @@
+package boids_test
+
+import (
+	"testing"
+
+	"github.com/lmas/akvarium/boids"
+	"github.com/lmas/akvarium/boids/metrics"
+)
+
+// Runs a preset headless for a number of steps, with the target in the middle of the spawn area.
+func runPreset(t *testing.T, name string, steps int) (metrics.Metrics, boids.Vector) {
+	conf, err := boids.PresetConf(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := boids.New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	target := conf.Spawn[0].Addv(conf.Spawn[1]).Div(2)
+	for i := 0; i < steps; i++ {
+		s.Update(i%2 == 0, target)
+	}
+	return metrics.Compute(s), target
+}
+
+func TestPresets(t *testing.T) {
+	if _, err := boids.PresetConf("unknown"); err == nil {
+		t.Error("got no error, expected unknown preset")
+	}
+	for _, p := range boids.Presets {
+		if err := p.Conf.Validate(); err != nil {
+			t.Errorf("got error %q, expected preset %s to be valid", err, p.Name)
+		}
+	}
+	if testing.Short() {
+		t.Skip("slow acceptance tests, running the presets as they ship")
+	}
+
+	t.Run("school", func(t *testing.T) {
+		m, target := runPreset(t, "school", 3000)
+		if d := m.Center.Distance(target); d > 300 {
+			t.Errorf("got center %0.f from target, expected the school to stay close", d)
+		}
+		if m.Radius > 400 {
+			t.Errorf("got radius %0.f, expected the school to stay together", m.Radius)
+		}
+	})
+	t.Run("murmuration", func(t *testing.T) {
+		m, _ := runPreset(t, "murmuration", 3000)
+		if m.Polarization < 0.9 {
+			t.Errorf("got polarization %0.2f, expected at least 0.9", m.Polarization)
+		}
+	})
+	t.Run("insects", func(t *testing.T) {
+		m, target := runPreset(t, "insects", 3000)
+		if m.Polarization > 0.3 {
+			t.Errorf("got polarization %0.2f, expected at most 0.3", m.Polarization)
+		}
+		if d := m.Center.Distance(target); d > 50 {
+			t.Errorf("got center %0.f from target, expected the swarm to stay around it", d)
+		}
+	})
+	t.Run("baitball", func(t *testing.T) {
+		m, _ := runPreset(t, "baitball", 3000)
+		if m.Milling < 0.8 {
+			t.Errorf("got milling %0.2f, expected at least 0.8", m.Milling)
+		}
+	})
+}
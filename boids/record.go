@@ -0,0 +1,391 @@
+package boids
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+)
+
+// RecordVersion is the current version of the recording format.
+const RecordVersion uint16 = 1
+
+// Magic bytes at the start of a recording.
+var recordMagic = [4]byte{'A', 'K', 'V', 'R'}
+
+// Inputs stored in a recording, each one followed by it's arguments.
+const (
+	recUpdate   byte = iota + 1 // dirty (byte), target (2 float64)
+	recStartle                  // pos (2 float64), radius (float64)
+	recEat                      // id (int64)
+	recHash                     // tick (uint64), hash (uint64)
+	recConf                     // size (uint32), Conf (JSON)
+	recInform                   // goalRecord, count (uint32), ids (int64 each)
+	recRoute                    // route (uint32), routeRecord, waypoints (2 float64 each)
+	recSetRoute                 // route (uint32, 0 for nil), count (uint32), ids (int64 each)
+)
+
+// recorder writes all inputs to a Swarm, see Swarm.Record.
+type recorder struct {
+	w         *bufio.Writer
+	hashEvery uint64
+	updates   uint64
+	routes    map[*Route]uint32 // Routes are numbered from 1, in the order they were first seen.
+	err       error
+}
+
+func (r *recorder) write(op byte, args ...interface{}) {
+	if r.err != nil {
+		return
+	}
+	r.err = r.w.WriteByte(op)
+	for _, a := range args {
+		if r.err != nil {
+			return
+		}
+		r.err = binary.Write(r.w, binary.LittleEndian, a)
+	}
+}
+
+// Record starts recording the Swarm to w, so the run can be played back exactly with a Player.
+// It writes a Snapshot of the current state, followed by all inputs given to Update, Startle,
+// Eat, Inform and SetRoute, and any changes from SetConf and Tune. Routes must not be changed
+// directly while recording, as only their state when passed to SetRoute is written.
+// A hash of the Swarm's state is also written
+// every hashEvery updates (0 disables it), which the Player uses to detect if the playback
+// diverges from the recorded run.
+// Only one recording can be active at a time. It must not be called concurrently with Update.
+func (s *Swarm) Record(w io.Writer, hashEvery int) error {
+	if s.recorder != nil {
+		return errors.New("already recording")
+	}
+	if hashEvery < 0 {
+		hashEvery = 0
+	}
+	bw := bufio.NewWriter(w)
+	for _, v := range []interface{}{recordMagic, RecordVersion} {
+		if err := binary.Write(bw, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+	if _, err := s.Snapshot().WriteTo(bw); err != nil {
+		return err
+	}
+	// Same order as in the Snapshot
+	routes := make(map[*Route]uint32, len(s.routes))
+	for i, r := range s.routes {
+		routes[r] = uint32(i + 1)
+	}
+	s.recorder = &recorder{w: bw, hashEvery: uint64(hashEvery), routes: routes}
+	return nil
+}
+
+// StopRecording stops an active recording and flushes it to the writer.
+// It returns the first error that happened while recording, if any.
+func (s *Swarm) StopRecording() error {
+	r := s.recorder
+	if r == nil {
+		return nil
+	}
+	s.recorder = nil
+	if r.err != nil {
+		return r.err
+	}
+	return r.w.Flush()
+}
+
+func (s *Swarm) recordUpdate(dirty bool, target Vector) {
+	var d byte
+	if dirty {
+		d = 1
+	}
+	s.recorder.write(recUpdate, d, target.X, target.Y)
+}
+
+// recordConf is called when changes from SetConf or Tune are applied.
+func (s *Swarm) recordConf(conf Conf) {
+	b, err := json.Marshal(conf)
+	if err != nil {
+		s.recorder.err = err
+		return
+	}
+	s.recorder.write(recConf, uint32(len(b)), b)
+}
+
+func int64s(ids []int) []int64 {
+	ids64 := make([]int64, len(ids))
+	for i, id := range ids {
+		ids64[i] = int64(id)
+	}
+	return ids64
+}
+
+func (s *Swarm) recordInform(goal Goal, ids []int) {
+	s.recorder.write(recInform, newGoalRecord(goal), uint32(len(ids)), int64s(ids))
+}
+
+// recordRoute writes the Route's current state the first time it's seen, so all later
+// calls with the same Route refers to the same one in the playback.
+func (s *Swarm) recordRoute(r *Route, ids []int) {
+	rec := s.recorder
+	var id uint32
+	if r != nil {
+		var ok bool
+		if id, ok = rec.routes[r]; !ok {
+			id = uint32(len(rec.routes) + 1)
+			rec.routes[r] = id
+			rs := r.state()
+			rec.write(recRoute, id, newRouteRecord(rs), rs.Waypoints)
+		}
+	}
+	rec.write(recSetRoute, id, uint32(len(ids)), int64s(ids))
+}
+
+// recordHash is called after each Update.
+func (s *Swarm) recordHash() {
+	r := s.recorder
+	r.updates++
+	if r.hashEvery > 0 && r.updates%r.hashEvery == 0 {
+		r.write(recHash, s.tick, s.Hash())
+	}
+}
+
+// Hash returns a hash of the Swarm's current tick and the state of all Boids.
+// Two Swarms with the same hash are (very likely) in the exact same state.
+// It must not be called concurrently with Update.
+func (s *Swarm) Hash() uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	write := func(u uint64) {
+		binary.LittleEndian.PutUint64(buf[:], u)
+		h.Write(buf[:])
+	}
+	write(s.tick)
+	for _, b := range s.Boids {
+		for _, f := range []float64{b.Pos.X, b.Pos.Y, b.Vel.X, b.Vel.Y, b.Heading, b.Alarm} {
+			write(math.Float64bits(f))
+		}
+	}
+	return h.Sum64()
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// DivergenceError is returned by a Player when the played back Swarm's state no longer matches
+// the recorded one.
+type DivergenceError struct {
+	Tick     uint64
+	Expected uint64
+	Got      uint64
+}
+
+func (e *DivergenceError) Error() string {
+	return fmt.Sprintf("playback diverged at tick %d: got state hash %#x, expected %#x", e.Tick, e.Got, e.Expected)
+}
+
+// Player plays back a recording made with Swarm.Record.
+type Player struct {
+	r      *bufio.Reader
+	swarm  *Swarm
+	routes []*Route // Indexed by the recorded route numbers, with nil at 0.
+}
+
+// NewPlayer reads the start of a recording and restores it's Swarm.
+func NewPlayer(r io.Reader) (*Player, error) {
+	br := bufio.NewReader(r)
+	var magic [4]byte
+	var version uint16
+	if err := binary.Read(br, binary.LittleEndian, &magic); err != nil {
+		return nil, fmt.Errorf("could not read recording: %s", err)
+	}
+	if magic != recordMagic {
+		return nil, errors.New("not a recording, bad magic bytes")
+	}
+	if err := binary.Read(br, binary.LittleEndian, &version); err != nil {
+		return nil, fmt.Errorf("could not read recording: %s", err)
+	}
+	if version != RecordVersion {
+		return nil, fmt.Errorf("unsupported recording version %d", version)
+	}
+	snap, err := ReadSnapshot(br)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Restore(snap)
+	if err != nil {
+		return nil, err
+	}
+	routes := append([]*Route{nil}, s.routes...)
+	return &Player{r: br, swarm: s, routes: routes}, nil
+}
+
+// Swarm returns the Swarm being played back.
+// It can be read from between calls to Step, but must not be changed.
+func (p *Player) Swarm() *Swarm {
+	return p.swarm
+}
+
+// Step plays back all recorded inputs up to and including the next Update.
+// Any inputs given by Hooks during the Update are played back at the start of the next Step,
+// as the Player's Swarm has no Hooks.
+// It returns io.EOF at the end of the recording, or a *DivergenceError if the state
+// no longer matches the recorded run.
+func (p *Player) Step() error {
+	for {
+		op, err := p.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		switch op {
+		case recUpdate:
+			var args struct {
+				Dirty  byte
+				Target Vector
+			}
+			if err := p.read(&args); err != nil {
+				return err
+			}
+			p.swarm.Update(args.Dirty != 0, args.Target)
+			// The hash follows right after, unless Hooks gave the Swarm more inputs during the
+			// Update. Those are played back first, at the start of the next Step.
+			if b, err := p.r.Peek(1); err != nil || b[0] != recHash {
+				return nil
+			}
+			if _, err := p.r.Discard(1); err != nil {
+				return err
+			}
+			return p.checkHash()
+		case recHash:
+			if err := p.checkHash(); err != nil {
+				return err
+			}
+		case recStartle:
+			var args struct {
+				Pos    Vector
+				Radius float64
+			}
+			if err := p.read(&args); err != nil {
+				return err
+			}
+			p.swarm.Startle(args.Pos, args.Radius)
+		case recConf:
+			var size uint32
+			if err := p.read(&size); err != nil {
+				return err
+			}
+			if size > maxConfSize {
+				return fmt.Errorf("bad recording, conf too large (%d bytes)", size)
+			}
+			b := make([]byte, size)
+			if _, err := io.ReadFull(p.r, b); err != nil {
+				return fmt.Errorf("bad recording: %w", err)
+			}
+			var conf Conf
+			if err := json.Unmarshal(b, &conf); err != nil {
+				return fmt.Errorf("bad recording: %w", err)
+			}
+			if err := p.swarm.SetConf(conf); err != nil {
+				return err
+			}
+		case recEat:
+			var id int64
+			if err := p.read(&id); err != nil {
+				return err
+			}
+			p.swarm.Eat(int(id))
+		case recInform:
+			var goal goalRecord
+			if err := p.read(&goal); err != nil {
+				return err
+			}
+			ids, err := p.readIDs()
+			if err != nil {
+				return err
+			}
+			p.swarm.Inform(goal.goal(), ids...)
+		case recRoute:
+			var id uint32
+			var r routeRecord
+			if err := p.read(&id); err != nil {
+				return err
+			}
+			if err := p.read(&r); err != nil {
+				return err
+			}
+			if id != uint32(len(p.routes)) || r.Waypoints > maxWaypoints {
+				return fmt.Errorf("bad recording, unexpected route %d", id)
+			}
+			rs := r.state()
+			if err := p.read(rs.Waypoints); err != nil {
+				return err
+			}
+			if err := rs.validate(); err != nil {
+				return fmt.Errorf("bad recording: %w", err)
+			}
+			p.routes = append(p.routes, rs.route())
+		case recSetRoute:
+			var id uint32
+			if err := p.read(&id); err != nil {
+				return err
+			}
+			if id >= uint32(len(p.routes)) {
+				return fmt.Errorf("bad recording, unknown route %d", id)
+			}
+			ids, err := p.readIDs()
+			if err != nil {
+				return err
+			}
+			p.swarm.SetRoute(p.routes[id], ids...)
+		default:
+			return fmt.Errorf("bad recording, unknown input %d", op)
+		}
+	}
+}
+
+// checkHash compares the state with a recorded hash.
+func (p *Player) checkHash() error {
+	var args struct {
+		Tick, Hash uint64
+	}
+	if err := p.read(&args); err != nil {
+		return err
+	}
+	if h := p.swarm.Hash(); p.swarm.tick != args.Tick || h != args.Hash {
+		return &DivergenceError{Tick: args.Tick, Expected: args.Hash, Got: h}
+	}
+	return nil
+}
+
+// readIDs reads a count followed by that many Boid ids.
+func (p *Player) readIDs() ([]int, error) {
+	var num uint32
+	if err := p.read(&num); err != nil {
+		return nil, err
+	}
+	if num > uint32(len(p.swarm.Boids)) {
+		return nil, fmt.Errorf("bad recording, too many boids (%d)", num)
+	}
+	ids64 := make([]int64, num)
+	if err := p.read(ids64); err != nil {
+		return nil, err
+	}
+	ids := make([]int, num)
+	for i, id := range ids64 {
+		ids[i] = int(id)
+	}
+	return ids, nil
+}
+
+func (p *Player) read(v interface{}) error {
+	if err := binary.Read(p.r, binary.LittleEndian, v); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("bad recording: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package boids
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestRecord(t *testing.T) {
+	conf := DefaultConf()
+	conf.Boids = 100
+	conf.AlarmRange = 30
+	conf.AlarmDecay = 0.15
+	conf.SpeedTrait = Uniform(0.8, 1.2)
+
+	s := mustNew(t, conf)
+	before := NewRoute(RouteLoop, 50, NewVector(100, 100), NewVector(300, 100))
+	s.SetRoute(before, 10, 11, 12)
+	for i := 0; i < 100; i++ {
+		s.Update(i%2 == 0, NewVector(0, 0))
+	}
+	var buf bytes.Buffer
+	if err := s.Record(&buf, 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Record(&buf, 10); err == nil {
+		t.Error("got no error, expected already recording")
+	}
+	for i := 0; i < 200; i++ {
+		switch i {
+		case 50:
+			s.Startle(s.Boids[0].Pos, 30)
+		case 60:
+			s.Inform(DirectionGoal(NewVector(1, 0), 0.05), 20, 21)
+		case 65:
+			s.Inform(Goal{})
+		case 70:
+			s.Inform(PointGoal(NewVector(600, 300), 0.05), 22, 23)
+		case 80:
+			s.Eat(3)
+		case 90:
+			s.SetRoute(NewRoute(RoutePingPong, 40, NewVector(0, 0), NewVector(200, 200)), 30, 31)
+		case 100:
+			s.SetRoute(before, 13)
+			s.SetRoute(s.Route(30), 32)
+		case 110:
+			s.SetRoute(nil, 10)
+		case 120:
+			if err := s.Tune(func(c *Conf) { c.CohesionFactor = 0.01 }); err != nil {
+				t.Fatal(err)
+			}
+		}
+		s.Update(i%2 == 0, NewVector(float64(i), 200))
+	}
+	if err := s.StopRecording(); err != nil {
+		t.Fatal(err)
+	}
+	rec := buf.Bytes()
+
+	t.Run("playback", func(t *testing.T) {
+		p, err := NewPlayer(bytes.NewReader(rec))
+		if err != nil {
+			t.Fatal(err)
+		}
+		steps := 0
+		for err = p.Step(); err == nil; err = p.Step() {
+			steps++
+		}
+		if !errors.Is(err, io.EOF) {
+			t.Fatalf("got error %q, expected end of recording", err)
+		}
+		if steps != 200 {
+			t.Errorf("got %d steps, expected 200", steps)
+		}
+		if p.Swarm().Hash() != s.Hash() {
+			t.Error("got a different state, expected the same as the recorded swarm")
+		}
+		if p.Swarm().Conf.CohesionFactor != 0.01 {
+			t.Errorf("got cohesion factor %v, expected 0.01", p.Swarm().Conf.CohesionFactor)
+		}
+		got, expected := p.Swarm().Snapshot(), s.Snapshot()
+		if !reflect.DeepEqual(got.Routes, expected.Routes) {
+			t.Errorf("got routes %+v, expected %+v", got.Routes, expected.Routes)
+		}
+		for i := range got.Boids {
+			g, e := got.Boids[i], expected.Boids[i]
+			if g.Route != e.Route || g.Role != e.Role || g.Goal != e.Goal {
+				t.Fatalf("got boid %d with route %d and role %d, expected route %d and role %d",
+					i, g.Route, g.Role, e.Route, e.Role)
+			}
+		}
+	})
+
+	t.Run("divergence", func(t *testing.T) {
+		p, err := NewPlayer(bytes.NewReader(rec))
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Swarm().Boids[0].Pos.X += 1
+		var div *DivergenceError
+		for err = p.Step(); err == nil; err = p.Step() {
+		}
+		if !errors.As(err, &div) {
+			t.Fatalf("got error %q, expected playback to diverge", err)
+		}
+		if div.Tick != 110 {
+			t.Errorf("got divergence at tick %d, expected 110", div.Tick)
+		}
+	})
+}
+
+func TestRecordHooks(t *testing.T) {
+	conf := DefaultConf()
+	conf.Boids = 100
+	conf.CollisionRange = 20
+	s := mustNew(t, conf)
+	eaten := 0
+	s.On(EventCollision, func(e Event) {
+		if e.Tick%20 == 0 {
+			s.Eat(e.Boid)
+			eaten++
+		}
+	})
+	var buf bytes.Buffer
+	if err := s.Record(&buf, 1); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 200; i++ {
+		s.Update(i%2 == 0, NewVector(640, 360))
+	}
+	if err := s.StopRecording(); err != nil {
+		t.Fatal(err)
+	}
+	if eaten < 1 {
+		t.Fatal("got no eaten boids, expected the hook to eat some")
+	}
+
+	p, err := NewPlayer(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := 0
+	for err = p.Step(); err == nil; err = p.Step() {
+		steps++
+	}
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("got error %q, expected end of recording", err)
+	}
+	if steps != 200 {
+		t.Errorf("got %d steps, expected 200", steps)
+	}
+	if p.Swarm().Hash() != s.Hash() {
+		t.Error("got a different state, expected the same as the recorded swarm")
+	}
+}
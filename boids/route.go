@@ -0,0 +1,175 @@
+package boids
+
+import (
+	"github.com/lmas/akvarium/boids/fixed"
+)
+
+// RouteMode decides what a Route does after reaching it's last waypoint.
+type RouteMode int
+
+const (
+	RouteOnce     RouteMode = iota // Stay at the last waypoint.
+	RouteLoop                      // Go back to the first waypoint, like a closed loop.
+	RoutePingPong                  // Go back through the waypoints in reverse order, then forward again.
+)
+
+// Route is a path of waypoints that a group of Boids follows, instead of the Swarm's target.
+// The group moves on to the next waypoint when it's center of mass is within the arrive radius
+// of the current one.
+type Route struct {
+	Waypoints []Vector
+	Radius    float64 // Arrive radius.
+	Mode      RouteMode
+
+	current int
+	dir     int
+	done    bool
+}
+
+// NewRoute returns a new Route, starting at the first waypoint.
+func NewRoute(mode RouteMode, radius float64, waypoints ...Vector) *Route {
+	return &Route{
+		Waypoints: waypoints,
+		Radius:    radius,
+		Mode:      mode,
+		dir:       1,
+	}
+}
+
+// Current returns the index of the current waypoint.
+func (r *Route) Current() int {
+	return r.current
+}
+
+// Target returns the current waypoint.
+func (r *Route) Target() Vector {
+	if len(r.Waypoints) < 1 {
+		return NewVector(0, 0)
+	}
+	return r.Waypoints[r.current]
+}
+
+// Done returns true when a RouteOnce has reached it's last waypoint.
+func (r *Route) Done() bool {
+	return r.done
+}
+
+// advance moves on to the next waypoint if center has arrived at the current one.
+func (r *Route) advance(center Vector) {
+	if r.done || len(r.Waypoints) < 1 {
+		return
+	}
+	diff := r.Target().Subv(center)
+	if diff.Dot(diff) > r.Radius*r.Radius {
+		return
+	}
+	r.next()
+}
+
+// advanceFixed is the same as advance, but using only integer math (see Conf.FixedPoint).
+func (r *Route) advanceFixed(center fixed.Vector) {
+	if r.done || len(r.Waypoints) < 1 {
+		return
+	}
+	diff := toFixed(r.Target()).Sub(center)
+	radius := fixed.FromFloat(r.Radius)
+	if diff.Dot(diff) > radius.Mul(radius) {
+		return
+	}
+	r.next()
+}
+
+// next moves on to the next waypoint, depending on the Mode.
+func (r *Route) next() {
+	last := len(r.Waypoints) - 1
+	switch {
+	case r.Mode == RouteLoop:
+		r.current = (r.current + 1) % len(r.Waypoints)
+	case r.Mode == RoutePingPong && last > 0:
+		if r.dir == 0 {
+			r.dir = 1
+		}
+		if r.current+r.dir < 0 || r.current+r.dir > last {
+			r.dir = -r.dir
+		}
+		r.current += r.dir
+	case r.current < last:
+		r.current++
+	default:
+		r.done = true
+	}
+}
+
+// SetRoute makes the Boids with ids follow a Route, or all Boids if no ids are given.
+// A nil Route makes the Boids go back to following the Swarm's target.
+// It must not be called concurrently with Update.
+func (s *Swarm) SetRoute(r *Route, ids ...int) {
+	if s.recorder != nil {
+		s.recordRoute(r, ids)
+	}
+	if len(ids) < 1 {
+		for _, b := range s.Boids {
+			b.route = r
+		}
+	}
+	for _, id := range ids {
+		if id >= 0 && id < len(s.Boids) {
+			s.Boids[id].route = r
+		}
+	}
+
+	// Forget any routes that no longer has any Boids
+	s.routes = s.routes[:0]
+	seen := make(map[*Route]bool)
+	for _, b := range s.Boids {
+		if b.route != nil && !seen[b.route] {
+			seen[b.route] = true
+			s.routes = append(s.routes, b.route)
+		}
+	}
+}
+
+// Route returns the Route a Boid is following, or nil.
+func (s *Swarm) Route(id int) *Route {
+	if id < 0 || id >= len(s.Boids) {
+		return nil
+	}
+	return s.Boids[id].route
+}
+
+// advanceRoutes lets each Route check if it's group of Boids has arrived at the current waypoint.
+func (s *Swarm) advanceRoutes() {
+	if len(s.routes) < 1 {
+		return
+	}
+	if s.Conf.FixedPoint {
+		s.advanceRoutesFixed()
+		return
+	}
+	sums := make(map[*Route]Vector, len(s.routes))
+	nums := make(map[*Route]float64, len(s.routes))
+	for _, b := range s.Boids {
+		if b.route != nil {
+			sums[b.route] = sums[b.route].Addv(b.Pos)
+			nums[b.route]++
+		}
+	}
+	for _, r := range s.routes {
+		r.advance(sums[r].Div(nums[r]))
+	}
+}
+
+// advanceRoutesFixed is the same as advanceRoutes, but using the Boids' fixed-point positions.
+func (s *Swarm) advanceRoutesFixed() {
+	sums := make(map[*Route]fixed.Vector, len(s.routes))
+	nums := make(map[*Route]int, len(s.routes))
+	for _, b := range s.Boids {
+		if b.route != nil {
+			sums[b.route] = sums[b.route].Add(b.fx.pos)
+			nums[b.route]++
+		}
+	}
+	for _, r := range s.routes {
+		r.advanceFixed(sums[r].Div(fixed.FromInt(nums[r])))
+	}
+}
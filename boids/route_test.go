@@ -0,0 +1,70 @@
+package boids
+
+import (
+	"testing"
+)
+
+func TestRoute(t *testing.T) {
+	wp := []Vector{{0, 0}, {100, 0}, {100, 100}}
+	tests := []struct {
+		mode RouteMode
+		path []int
+	}{
+		{RouteOnce, []int{1, 2, 2, 2}},
+		{RouteLoop, []int{1, 2, 0, 1}},
+		{RoutePingPong, []int{1, 2, 1, 0, 1}},
+	}
+	for _, tt := range tests {
+		r := NewRoute(tt.mode, 10, wp...)
+		// Far away from everything, so nothing should happen
+		r.advance(NewVector(50, 50))
+		if r.Current() != 0 {
+			t.Errorf("got waypoint %d, expected 0 (not arrived)", r.Current())
+		}
+		for i, e := range tt.path {
+			r.advance(r.Target().Add(5))
+			if r.Current() != e {
+				t.Errorf("mode %d step %d: got waypoint %d, expected %d", tt.mode, i, r.Current(), e)
+			}
+		}
+		if done := tt.mode == RouteOnce; r.Done() != done {
+			t.Errorf("mode %d: got done %v, expected %v", tt.mode, r.Done(), done)
+		}
+	}
+}
+
+func TestSwarmRoute(t *testing.T) {
+	s := mustNew(t, Conf{
+		Boids:   20,
+		Workers: 2,
+		Spawn: [2]Vector{
+			NewVector(0, 0),
+			NewVector(20, 20),
+		},
+		IndexOffset:         50,
+		CohesionFactor:      0.01,
+		AlignmentFactor:     0.05,
+		SeparationRange:     5,
+		SeparationFactor:    0.3,
+		TargetRange:         5,
+		TargetAttractFactor: 0.01,
+		VelocityMax:         2,
+		VelocityMin:         0.5,
+	})
+	r := NewRoute(RouteOnce, 30, NewVector(200, 0), NewVector(200, 200))
+	s.SetRoute(r, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9)
+	if s.Route(0) != r || s.Route(10) != nil {
+		t.Fatalf("got routes %v and %v, expected only first half on the route", s.Route(0), s.Route(10))
+	}
+	s.SetRoute(r)
+	for i := 0; i < 2000 && !r.Done(); i++ {
+		s.Update(i%2 == 0, NewVector(0, 0))
+	}
+	if !r.Done() {
+		t.Errorf("got waypoint %d, expected the route to be done", r.Current())
+	}
+	s.SetRoute(nil)
+	if len(s.routes) != 0 {
+		t.Errorf("got %d routes, expected none", len(s.routes))
+	}
+}
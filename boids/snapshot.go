@@ -0,0 +1,435 @@
+package boids
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+)
+
+// SnapshotVersion is the current version of the Snapshot format.
+// Snapshots of any other version are rejected.
+const SnapshotVersion uint16 = 1
+
+// Magic bytes at the start of a binary encoded Snapshot.
+var snapshotMagic = [4]byte{'A', 'K', 'V', 'S'}
+
+// Snapshot is the full state of a Swarm, which can be restored later with Restore.
+// It's encoded as JSON with encoding/json, or in a compact binary format with
+// WriteTo and ReadSnapshot.
+//
+// Hooks and regions are not part of the state and must be set up again after restoring,
+// in the same order as before.
+type Snapshot struct {
+	Version uint16
+	Conf    Conf
+	Tick    uint64
+	Draws   uint64 // Number of values drawn from the random source, after seeding it with Conf.Seed.
+	Boids   []BoidState
+	Routes  []RouteState
+}
+
+// BoidState holds all the state of a Boid.
+type BoidState struct {
+	ID      int
+	Pos     Vector
+	Vel     Vector
+	Heading float64
+	Traits  Traits
+	Role    Role
+	Goal    Goal
+	Alarm   float64
+	Pending float64 // Alarm heard from a neighbour, waiting for Delay updates.
+	Delay   int
+	Regions uint64 // Bitmask of the regions the Boid is inside.
+	Route   int    // Position in Snapshot.Routes plus one, or 0 if not following a Route.
+}
+
+// RouteState holds all the state of a Route.
+type RouteState struct {
+	Waypoints []Vector
+	Radius    float64
+	Mode      RouteMode
+	Current   int
+	Dir       int
+	Done      bool
+}
+
+func (r *Route) state() RouteState {
+	return RouteState{
+		Waypoints: append([]Vector(nil), r.Waypoints...),
+		Radius:    r.Radius,
+		Mode:      r.Mode,
+		Current:   r.current,
+		Dir:       r.dir,
+		Done:      r.done,
+	}
+}
+
+func (rs RouteState) route() *Route {
+	return &Route{
+		Waypoints: append([]Vector(nil), rs.Waypoints...),
+		Radius:    rs.Radius,
+		Mode:      rs.Mode,
+		current:   rs.Current,
+		dir:       rs.Dir,
+		done:      rs.Done,
+	}
+}
+
+// Snapshot returns the current state of the Swarm.
+// It must not be called concurrently with Update.
+func (s *Swarm) Snapshot() *Snapshot {
+	snap := &Snapshot{
+		Version: SnapshotVersion,
+		Conf:    s.Conf,
+		Tick:    s.tick,
+		Draws:   s.source.draws,
+		Boids:   make([]BoidState, len(s.Boids)),
+	}
+	routes := make(map[*Route]int, len(s.routes))
+	for i, r := range s.routes {
+		snap.Routes = append(snap.Routes, r.state())
+		routes[r] = i + 1
+	}
+	for i, b := range s.Boids {
+		snap.Boids[i] = BoidState{
+			ID:      b.ID,
+			Pos:     b.Pos,
+			Vel:     b.Vel,
+			Heading: b.Heading,
+			Traits:  b.Traits,
+			Role:    b.Role,
+			Goal:    b.Goal,
+			Alarm:   b.Alarm,
+			Pending: b.alarm.pending,
+			Delay:   b.alarm.delay,
+			Regions: b.regions,
+			Route:   routes[b.route],
+		}
+	}
+	return snap
+}
+
+// Restore creates a new Swarm from a Snapshot, that continues exactly where the old one
+// left off (given the same hooks and regions are set up again).
+// The Swarm's Routes are new copies, which can be found with Swarm.Route.
+func Restore(snap *Snapshot) (*Swarm, error) {
+	if snap.Version != SnapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", snap.Version)
+	}
+	if len(snap.Boids) != snap.Conf.Boids {
+		return nil, fmt.Errorf("snapshot has %d boids, expected %d", len(snap.Boids), snap.Conf.Boids)
+	}
+	s, err := New(snap.Conf)
+	if err != nil {
+		return nil, err
+	}
+	for i, rs := range snap.Routes {
+		if err := rs.validate(); err != nil {
+			s.Close()
+			return nil, fmt.Errorf("snapshot route %d: %s", i, err)
+		}
+		s.routes = append(s.routes, rs.route())
+	}
+	for i, bs := range snap.Boids {
+		if bs.ID != i {
+			s.Close()
+			return nil, fmt.Errorf("snapshot has boid ID %d at position %d", bs.ID, i)
+		}
+		if bs.Route < 0 || bs.Route > len(s.routes) {
+			s.Close()
+			return nil, fmt.Errorf("snapshot has boid %d following unknown route %d", i, bs.Route)
+		}
+		b := s.Boids[i]
+		b.Pos, b.Vel, b.Heading = bs.Pos, bs.Vel, bs.Heading
+		b.Traits, b.Role, b.Goal = bs.Traits, bs.Role, bs.Goal
+		b.Alarm, b.alarm = bs.Alarm, alarm{bs.Alarm, bs.Pending, bs.Delay}
+		b.regions = bs.Regions
+		if bs.Route > 0 {
+			b.route = s.routes[bs.Route-1]
+		}
+		if s.Conf.FixedPoint {
+			s.syncFixed(b)
+		}
+	}
+	// The random source can only be restored by drawing all values again, so a corrupted
+	// snapshot could otherwise keep it busy for a very long time
+	extra := snap.Draws - s.source.draws
+	if snap.Draws < s.source.draws || extra > maxDraws || extra/(spawnDraws*uint64(len(s.Boids))) > snap.Tick {
+		s.Close()
+		return nil, fmt.Errorf("snapshot has an unexpected number of random draws (%d) for tick %d", snap.Draws, snap.Tick)
+	}
+	s.tick = snap.Tick
+	s.source.skip(snap.Conf.Seed, snap.Draws)
+	s.Index.Update(s.Boids)
+	return s, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// boidRecord is the fixed size binary layout of a BoidState.
+type boidRecord struct {
+	ID                  int64
+	PosX, PosY          float64
+	VelX, VelY          float64
+	Heading             float64
+	Speed, Size, Social float64
+	Role                int64
+	Goal                goalRecord
+	Alarm, Pending      float64
+	Delay               int64
+	Regions             uint64
+}
+
+// goalRecord is the fixed size binary layout of a Goal.
+type goalRecord struct {
+	Kind         int64
+	X, Y, Weight float64
+}
+
+func newGoalRecord(g Goal) goalRecord {
+	return goalRecord{int64(g.Kind), g.V.X, g.V.Y, g.Weight}
+}
+
+func (r goalRecord) goal() Goal {
+	return Goal{GoalKind(r.Kind), Vector{r.X, r.Y}, r.Weight}
+}
+
+// routeRecord is the fixed size binary header of a RouteState, followed by it's waypoints.
+type routeRecord struct {
+	Radius    float64
+	Mode      int64
+	Current   int64
+	Dir       int64
+	Done      bool
+	Waypoints uint32
+}
+
+func newRouteRecord(rs RouteState) routeRecord {
+	return routeRecord{rs.Radius, int64(rs.Mode), int64(rs.Current), int64(rs.Dir), rs.Done, uint32(len(rs.Waypoints))}
+}
+
+// state returns the RouteState, with room for reading the waypoints.
+func (r routeRecord) state() RouteState {
+	return RouteState{
+		Waypoints: make([]Vector, r.Waypoints),
+		Radius:    r.Radius,
+		Mode:      RouteMode(r.Mode),
+		Current:   int(r.Current),
+		Dir:       int(r.Dir),
+		Done:      r.Done,
+	}
+}
+
+// validate checks that the RouteState can be restored.
+func (rs RouteState) validate() error {
+	if rs.Current < 0 || (rs.Current > 0 && rs.Current >= len(rs.Waypoints)) {
+		return fmt.Errorf("route is at unknown waypoint %d", rs.Current)
+	}
+	return nil
+}
+
+// WriteTo writes the Snapshot in the binary format, which is a header with the magic bytes
+// "AKVS", the format version, tick, random draws and the Conf (as JSON, so it can grow new
+// fields without breaking older snapshots), followed by the Boids' states (each one followed by
+// it's route) and the Routes' states.
+// All numbers are little endian.
+func (snap *Snapshot) WriteTo(w io.Writer) (int64, error) {
+	conf, err := json.Marshal(snap.Conf)
+	if err != nil {
+		return 0, err
+	}
+	cw := &countWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	header := []interface{}{
+		snapshotMagic,
+		SnapshotVersion,
+		snap.Tick,
+		snap.Draws,
+		uint32(len(conf)),
+		conf,
+		uint32(len(snap.Boids)),
+	}
+	for _, v := range header {
+		if err := binary.Write(bw, binary.LittleEndian, v); err != nil {
+			return cw.n, err
+		}
+	}
+	for _, b := range snap.Boids {
+		r := boidRecord{
+			int64(b.ID), b.Pos.X, b.Pos.Y, b.Vel.X, b.Vel.Y, b.Heading,
+			b.Traits.Speed, b.Traits.Size, b.Traits.Social,
+			int64(b.Role), newGoalRecord(b.Goal), b.Alarm, b.Pending, int64(b.Delay), b.Regions,
+		}
+		for _, v := range []interface{}{r, int64(b.Route)} {
+			if err := binary.Write(bw, binary.LittleEndian, v); err != nil {
+				return cw.n, err
+			}
+		}
+	}
+	if err := binary.Write(bw, binary.LittleEndian, uint32(len(snap.Routes))); err != nil {
+		return cw.n, err
+	}
+	for _, rs := range snap.Routes {
+		for _, v := range []interface{}{newRouteRecord(rs), rs.Waypoints} {
+			if err := binary.Write(bw, binary.LittleEndian, v); err != nil {
+				return cw.n, err
+			}
+		}
+	}
+	err = bw.Flush()
+	return cw.n, err
+}
+
+// Max size of the JSON encoded Conf, as a sanity check against corrupted snapshots.
+const maxConfSize uint32 = 1 << 20
+
+// Max number of waypoints in a Route, as a sanity check against corrupted snapshots.
+const maxWaypoints uint32 = 1 << 16
+
+// Max number of random values drawn after creating a Swarm, as a sanity check against corrupted
+// snapshots. Respawning an eaten Boid draws spawnDraws values, so it allows for each Boid being
+// eaten once per update (on average), up to maxDraws in total.
+const (
+	spawnDraws uint64 = 2
+	maxDraws   uint64 = 1 << 30
+)
+
+// ReadSnapshot reads a Snapshot in the binary format, see Snapshot.WriteTo.
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	br := bufio.NewReader(r)
+	var magic [4]byte
+	if err := binary.Read(br, binary.LittleEndian, &magic); err != nil {
+		return nil, fmt.Errorf("could not read snapshot: %s", err)
+	}
+	if magic != snapshotMagic {
+		return nil, errors.New("not a snapshot, bad magic bytes")
+	}
+	snap := &Snapshot{}
+	if err := binary.Read(br, binary.LittleEndian, &snap.Version); err != nil {
+		return nil, fmt.Errorf("could not read snapshot: %s", err)
+	}
+	if snap.Version != SnapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", snap.Version)
+	}
+	var size uint32
+	for _, v := range []interface{}{&snap.Tick, &snap.Draws, &size} {
+		if err := binary.Read(br, binary.LittleEndian, v); err != nil {
+			return nil, fmt.Errorf("could not read snapshot: %s", err)
+		}
+	}
+	if size > maxConfSize {
+		return nil, fmt.Errorf("snapshot conf too large (%d bytes)", size)
+	}
+	conf := make([]byte, size)
+	if _, err := io.ReadFull(br, conf); err != nil {
+		return nil, fmt.Errorf("could not read snapshot conf: %s", err)
+	}
+	if err := json.Unmarshal(conf, &snap.Conf); err != nil {
+		return nil, fmt.Errorf("could not decode snapshot conf: %s", err)
+	}
+	var num uint32
+	if err := binary.Read(br, binary.LittleEndian, &num); err != nil {
+		return nil, fmt.Errorf("could not read snapshot: %s", err)
+	}
+	if int64(num) != int64(snap.Conf.Boids) {
+		return nil, fmt.Errorf("snapshot has %d boids, expected %d", num, snap.Conf.Boids)
+	}
+	snap.Boids = make([]BoidState, num)
+	for i := range snap.Boids {
+		var r boidRecord
+		if err := binary.Read(br, binary.LittleEndian, &r); err != nil {
+			return nil, fmt.Errorf("could not read snapshot boid %d: %s", i, err)
+		}
+		var route int64
+		if err := binary.Read(br, binary.LittleEndian, &route); err != nil {
+			return nil, fmt.Errorf("could not read snapshot boid %d: %s", i, err)
+		}
+		snap.Boids[i] = BoidState{
+			ID:      int(r.ID),
+			Pos:     Vector{r.PosX, r.PosY},
+			Vel:     Vector{r.VelX, r.VelY},
+			Heading: r.Heading,
+			Traits:  Traits{r.Speed, r.Size, r.Social},
+			Role:    Role(r.Role),
+			Goal:    r.Goal.goal(),
+			Alarm:   r.Alarm,
+			Pending: r.Pending,
+			Delay:   int(r.Delay),
+			Regions: r.Regions,
+			Route:   int(route),
+		}
+	}
+	if err := binary.Read(br, binary.LittleEndian, &num); err != nil {
+		return nil, fmt.Errorf("could not read snapshot: %s", err)
+	}
+	if num > uint32(snap.Conf.Boids) {
+		return nil, fmt.Errorf("snapshot has %d routes, expected at most one per boid", num)
+	}
+	for i := 0; i < int(num); i++ {
+		var r routeRecord
+		if err := binary.Read(br, binary.LittleEndian, &r); err != nil {
+			return nil, fmt.Errorf("could not read snapshot route %d: %s", i, err)
+		}
+		if r.Waypoints > maxWaypoints {
+			return nil, fmt.Errorf("snapshot route %d has too many waypoints (%d)", i, r.Waypoints)
+		}
+		rs := r.state()
+		if err := binary.Read(br, binary.LittleEndian, rs.Waypoints); err != nil {
+			return nil, fmt.Errorf("could not read snapshot route %d: %s", i, err)
+		}
+		snap.Routes = append(snap.Routes, rs)
+	}
+	return snap, nil
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// countingSource keeps count of the number of values drawn from a random source,
+// so it's state can be saved and restored without access to it's internals.
+type countingSource struct {
+	src   rand.Source64
+	draws uint64
+}
+
+func newCountingSource(seed int64) *countingSource {
+	return &countingSource{src: rand.NewSource(seed).(rand.Source64)} //nolint:gosec
+}
+
+func (c *countingSource) Int63() int64 {
+	c.draws++
+	return c.src.Int63()
+}
+
+func (c *countingSource) Uint64() uint64 {
+	c.draws++
+	return c.src.Uint64()
+}
+
+func (c *countingSource) Seed(seed int64) {
+	c.src.Seed(seed)
+	c.draws = 0
+}
+
+// skip reseeds the source and then skips ahead past the already drawn values.
+func (c *countingSource) skip(seed int64, draws uint64) {
+	c.Seed(seed)
+	for c.draws < draws {
+		c.Uint64()
+	}
+}
@@ -0,0 +1,113 @@
+package boids
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	conf := DefaultConf()
+	conf.Boids = 100
+	conf.AlarmRange = 30
+	conf.AlarmDecay = 0.15
+	conf.AlarmSpeed = 1
+	conf.MaxTurnRate = 0.2
+	conf.SpeedTrait = Uniform(0.8, 1.2)
+	conf.SizeTrait = Normal(1, 0.1)
+
+	// Runs a swarm with some random and alarmed boids
+	target := NewVector(640, 360)
+	step := func(s *Swarm, steps int) {
+		for i := 0; i < steps; i++ {
+			s.Update(i%2 == 0, target)
+			if i%50 == 0 {
+				s.Eat(i % len(s.Boids))
+				s.Startle(s.Boids[0].Pos, 20)
+			}
+		}
+	}
+
+	encodings := map[string]func(*testing.T, *Snapshot) *Snapshot{
+		"binary": func(t *testing.T, snap *Snapshot) *Snapshot {
+			var buf bytes.Buffer
+			if _, err := snap.WriteTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+			snap, err := ReadSnapshot(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return snap
+		},
+		"json": func(t *testing.T, snap *Snapshot) *Snapshot {
+			b, err := json.Marshal(snap)
+			if err != nil {
+				t.Fatal(err)
+			}
+			snap = &Snapshot{}
+			if err := json.Unmarshal(b, snap); err != nil {
+				t.Fatal(err)
+			}
+			return snap
+		},
+	}
+	for name, encode := range encodings {
+		t.Run(name, func(t *testing.T) {
+			s := mustNew(t, conf)
+			s.SetRoute(NewRoute(RoutePingPong, 30, NewVector(100, 100), NewVector(500, 300), NewVector(600, 100)), 1, 2, 3)
+			s.SetRoute(NewRoute(RouteOnce, 30, NewVector(640, 360)), 4)
+			step(s, 201)
+			snap := s.Snapshot()
+			r, err := Restore(encode(t, snap))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(r.Snapshot(), snap) {
+				t.Fatal("got a different state, expected the restored swarm to be the same")
+			}
+			if r.Route(1) == nil || r.Route(1) != r.Route(3) || r.Route(1) == r.Route(4) || r.Route(0) != nil {
+				t.Error("got different routes, expected the restored boids to share the same routes as before")
+			}
+
+			step(s, 300)
+			step(r, 300)
+			if s.Tick() != r.Tick() {
+				t.Errorf("got tick %d, expected %d", r.Tick(), s.Tick())
+			}
+			if !reflect.DeepEqual(r.Snapshot(), s.Snapshot()) {
+				t.Error("got a different state, expected the restored swarm to continue the same way")
+			}
+		})
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		if _, err := ReadSnapshot(bytes.NewBufferString("nope")); err == nil {
+			t.Error("got no error, expected bad magic bytes")
+		}
+		snap := mustNew(t, conf).Snapshot()
+		snap.Version = SnapshotVersion + 1
+		if _, err := Restore(snap); err == nil {
+			t.Error("got no error, expected unsupported version")
+		}
+		snap.Version = SnapshotVersion
+		for _, draws := range []uint64{0, math.MaxUint64, snap.Draws + 2*uint64(conf.Boids)*10} {
+			snap.Draws = draws
+			if _, err := Restore(snap); err == nil {
+				t.Errorf("got no error, expected %d random draws at tick %d to be rejected", draws, snap.Tick)
+			}
+		}
+		eaten := mustNew(t, conf)
+		eaten.Eat(0)
+		eaten.Eat(1)
+		if _, err := Restore(eaten.Snapshot()); err != nil {
+			t.Errorf("got error %q, expected eaten boids before the first update to be restored", err)
+		}
+		snap.Boids = snap.Boids[1:]
+		if _, err := Restore(snap); err == nil {
+			t.Error("got no error, expected missing boids")
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package boids
+
+import (
+	"sync"
+	"time"
+)
+
+// StatsBuckets are the upper bounds of the buckets in Stats.Buckets.
+var StatsBuckets = []time.Duration{
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	1 * time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+}
+
+// Stats are performance counters for a Swarm, summed up over all Updates.
+type Stats struct {
+	Updates    uint64
+	Dirty      uint64          // Number of Updates that updated the velocities (and the Index).
+	Duration   time.Duration   // Time spent in Update.
+	Index      time.Duration   // Time spent building the Index, only done by dirty Updates.
+	Compute    time.Duration   // Time spent running the workers, including any collision passes.
+	Workers    []time.Duration // Time each worker was busy.
+	Neighbours uint64          // Number of neighbours visited by all Boids.
+	Buckets    []uint64        // Number of Updates that took at most each of StatsBuckets (cumulative).
+}
+
+type stats struct {
+	mu sync.Mutex // Protects Stats, so it can be read while updating.
+	Stats
+}
+
+// Stats returns a copy of the Swarm's performance counters.
+// Unlike most other methods, it's safe to call concurrently with Update.
+func (s *Swarm) Stats() Stats {
+	s.stats.mu.Lock()
+	defer s.stats.mu.Unlock()
+	st := s.stats.Stats
+	st.Workers = append([]time.Duration(nil), st.Workers...)
+	st.Buckets = append([]uint64(nil), st.Buckets...)
+	return st
+}
+
+// addStats sums up the timings for an Update and resets the workers' counters.
+func (s *Swarm) addStats(dirty bool, total, index, compute time.Duration) {
+	s.stats.mu.Lock()
+	defer s.stats.mu.Unlock()
+	st := &s.stats.Stats
+	if st.Workers == nil {
+		st.Workers = make([]time.Duration, len(s.workers))
+		st.Buckets = make([]uint64, len(StatsBuckets))
+	}
+	st.Updates++
+	if dirty {
+		st.Dirty++
+	}
+	st.Duration += total
+	st.Index += index
+	st.Compute += compute
+	for i, w := range s.workers {
+		st.Workers[i] += w.busy
+		st.Neighbours += w.visited
+		w.busy, w.visited = 0, 0
+	}
+	for i, b := range StatsBuckets {
+		if total <= b {
+			st.Buckets[i]++
+		}
+	}
+}
@@ -3,13 +3,18 @@ package boids
 import (
 	"math/rand"
 	"sync"
+	"time"
 )
 
+// Conf holds the parameters for a Swarm, see DefaultConf.
+// FixedPoint doesn't support alarms, turn rates, hard collisions or normal trait distributions
+// (see Validate), so Startle does nothing in that mode.
 type Conf struct {
 	Spawn       [2]Vector // Bounding box of min/max vector where boids spawn.
 	Seed        int64     // Randomisation seed.
 	Boids       int       // Number of boids to spawn.
 	Workers     int       // Number of goroutines that runs boid calculations.
+	FixedPoint  bool      // Use only integer math, so the same Seed gives the same result on all platforms.
 	IndexOffset int       // Size (in pixels) of each "cell" in the spatial index used to group boids.
 
 	// Variables used for boid movement calculation.
@@ -22,17 +27,56 @@ type Conf struct {
 	TargetAttractFactor float64
 	VelocityMax         float64
 	VelocityMin         float64
+	MaxForce            float64 // Max steering force applied per update, or 0 for no limit.
+	MaxTurnRate         float64 // Max heading change (in radians) per update, or 0 for no limit.
+
+	// Variables used for startle waves, see Swarm.Startle.
+	AlarmRange      float64 // Max distance an alarm spreads between neighbours, or 0 to not spread alarms.
+	AlarmDelay      int     // Number of (dirty) updates before a Boid reacts to an alarmed neighbour.
+	AlarmDecay      float64 // How much an alarm fades per (dirty) update and per neighbour it spreads to, 0-1.
+	AlarmThreshold  float64 // Min alarm level that is passed on to neighbours.
+	AlarmSpeed      float64 // Extra speed at full alarm, eg. 1 doubles VelocityMin and VelocityMax.
+	AlarmSeparation float64 // Extra separation at full alarm, eg. 1 doubles the SeparationFactor.
+
+	// Variables used for detecting events, see Swarm.On.
+	CollisionRange float64 // Boids closer than this fires an EventCollision.
+	ClusterRange   float64 // Max distance between Boids in the same cluster, defaults to IndexOffset.
+	ClusterMinSize int     // Min number of Boids moving between clusters, to count as a split or merge.
+
+	// Variables used for hard collision resolution, after moving the Boids.
+	BodyRadius          float64 // Radius of a Boid's body (scaled by it's size trait), or 0 to allow overlaps.
+	CollisionIterations int     // Max number of passes used for pushing apart overlapping Boids.
+
+	// Distributions for each Boid's personal traits, sampled using Seed.
+	SpeedTrait  Distribution
+	SizeTrait   Distribution
+	SocialTrait Distribution
 }
 
 // Swarm is a group of Boids.
 // It is moving together most of the time.
 type Swarm struct {
-	Conf  Conf
+	Conf  Conf // Read only, use SetConf or Tune to change it.
 	Boids []*Boid
 	Index *Index
 
-	signal                chan workerSignal
+	rand                  *rand.Rand
+	source                *countingSource
+	workers               []*worker
 	wg                    sync.WaitGroup
+	mu                    sync.Mutex // Protects pending.
+	pending               *Conf
+	tick                  uint64
+	hooks                 [numEvents][]Hook
+	regions               []region
+	routes                []*Route
+	tracker               *ClusterTracker
+	recorder              *recorder
+	stats                 stats
+	closed                bool
+	fixed                 fixedConf
+	squareAlarmRange      float64
+	squareCollisionRange  float64
 	squareSeparationRange float64
 	squareTargetRange     float64
 	squareVelocityMax     float64
@@ -42,70 +86,237 @@ type Swarm struct {
 // New creates a new swarm of Boids, using Conf.
 // It randomises the positions of each Boid and fires up a group of background
 // workers to perform the actual Boid movement updates.
-func New(conf Conf) *Swarm {
+// An error is returned if the Conf is invalid, see Conf.Validate.
+func New(conf Conf) (*Swarm, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
 	s := &Swarm{
-		Conf:                  conf,
-		Boids:                 make([]*Boid, conf.Boids),
-		Index:                 NewIndex(conf.IndexOffset),
-		signal:                make(chan workerSignal, conf.Workers),
-		squareSeparationRange: conf.SeparationRange * conf.SeparationRange,
-		squareTargetRange:     conf.TargetRange * conf.TargetRange,
-		squareVelocityMax:     conf.VelocityMax * conf.VelocityMax,
-		squareVelocityMin:     conf.VelocityMin * conf.VelocityMin,
-	}
-
-	min, max := conf.Spawn[0], conf.Spawn[1]
-	rand.Seed(conf.Seed)
+		Boids:   make([]*Boid, conf.Boids),
+		Index:   NewIndex(conf.IndexOffset),
+		source:  newCountingSource(conf.Seed),
+		workers: make([]*worker, conf.Workers),
+	}
+	s.rand = rand.New(s.source) //nolint:gosec
+	s.setConf(conf)
+
 	for i := 0; i < conf.Boids; i++ {
 		s.Boids[i] = &Boid{
-			ID: i,
-			Pos: NewVector(
-				min.X+rand.Float64()*(max.X-min.X), //nolint:gosec
-				min.Y+rand.Float64()*(max.Y-min.Y), //nolint:gosec
-			),
+			ID:  i,
+			Pos: s.spawnPos(),
 			Vel: NewVector(0, 0),
 		}
 	}
+	// Sampled after the positions, so the spawn layout stays the same for a seed.
+	for _, b := range s.Boids {
+		b.Traits = conf.sampleTraits(s.rand)
+		if conf.FixedPoint {
+			s.syncFixed(b)
+		}
+	}
 
-	// TODO: grab any leftovers if the flock wasn't divided up evenly
-	worker := conf.Boids / conf.Workers
+	// Any leftovers are spread out over the workers, if the flock can't be divided up evenly
 	for i := 0; i < conf.Workers; i++ {
-		boids := s.Boids[i*worker : (i*worker)+worker]
-		go s.workerUpdate(boids)
+		s.workers[i] = &worker{
+			signal: make(chan workerSignal, 1),
+			boids:  s.Boids[i*conf.Boids/conf.Workers : (i+1)*conf.Boids/conf.Workers],
+		}
+		go s.workerUpdate(s.workers[i])
+	}
+	return s, nil
+}
+
+// Inform sets the Role for the Boids with ids to RoleInformed, steering towards the goal,
+// and sets all other Boids that aren't informed already to RoleNaive. So several groups can be
+// informed with different goals (or weights), by calling it once for each group.
+// Calling it without any ids sets all Boids back to RoleDefault.
+// It must not be called concurrently with Update.
+func (s *Swarm) Inform(goal Goal, ids ...int) {
+	if s.recorder != nil {
+		s.recordInform(goal, ids)
+	}
+	if len(ids) < 1 {
+		for _, b := range s.Boids {
+			b.Role, b.Goal = RoleDefault, Goal{}
+		}
+		return
+	}
+	for _, b := range s.Boids {
+		if b.Role != RoleInformed {
+			b.Role = RoleNaive
+		}
+	}
+	for _, id := range ids {
+		if id >= 0 && id < len(s.Boids) {
+			s.Boids[id].Role = RoleInformed
+			s.Boids[id].Goal = goal
+		}
 	}
-	return s
+}
+
+// spawnPos returns a random position within the Spawn bounding box.
+// The explicit float64 conversions prevents the compiler from fusing the multiplications
+// and additions (FMA) on some architectures, which would break Conf.FixedPoint.
+func (s *Swarm) spawnPos() Vector {
+	min, max := s.Conf.Spawn[0], s.Conf.Spawn[1]
+	return NewVector(
+		min.X+float64(s.rand.Float64()*(max.X-min.X)),
+		min.Y+float64(s.rand.Float64()*(max.Y-min.Y)),
+	)
+}
+
+// Tick returns the number of updates the Swarm has run.
+func (s *Swarm) Tick() uint64 {
+	return s.tick
 }
 
 // Update all Boids' velocity (dirty, slow) or position (non-dirty, fast).
-// It also updates the Boid neighbour index if dirty, before hand.
+// It also updates the Boid neighbour index and any Routes if dirty, before hand.
+// Boids moves towards the target, unless they're following a Route.
+// If Conf.BodyRadius is set, any overlapping Boids are pushed apart after moving them.
+// Any changes from SetConf or Tune are applied first.
 func (s *Swarm) Update(dirty bool, target Vector) {
-	// TODO: could allow multiple targets?
+	start := time.Now()
+	s.applyPending()
+	if s.recorder != nil {
+		s.recordUpdate(dirty, target)
+	}
+	var index time.Duration
 	if dirty {
+		i := time.Now()
 		s.Index.Update(s.Boids)
+		index = time.Since(i)
+		s.advanceRoutes()
 	}
 
-	sig := workerSignal{dirty, target}
-	s.wg.Add(s.Conf.Workers)
-	for i := 0; i < s.Conf.Workers; i++ {
-		s.signal <- sig
+	compute := time.Now()
+	s.run(workerSignal{passUpdate, dirty, target})
+	if dirty {
+		// The new velocities and alarms can't be set by the workers themselves, as other
+		// workers might still be reading the old ones while looking at their neighbours.
+		for _, w := range s.workers {
+			for _, b := range w.boids {
+				b.Vel = b.next
+				b.Alarm = b.alarm.level
+				b.fx.vel = b.fx.next
+			}
+		}
+	} else if s.Conf.BodyRadius > 0 && !s.Conf.FixedPoint {
+		s.resolveCollisions()
+	}
+	end := time.Now()
+	s.addStats(dirty, end.Sub(start), index, end.Sub(compute))
+	s.deliver(dirty)
+	s.tick++
+	if s.recorder != nil {
+		s.recordHash()
+	}
+}
+
+// resolveCollisions pushes apart overlapping Boids, until there's no overlaps left
+// or it runs out of iterations.
+// The pushes are calculated and applied in separate passes, so workers never move a Boid
+// while another worker is reading it's position.
+// The Index isn't updated between passes, so it assumes the Boids only moved a short
+// distance (compared to the IndexOffset) since the last dirty update.
+func (s *Swarm) resolveCollisions() {
+	iterations := s.Conf.CollisionIterations
+	if iterations < 1 {
+		iterations = 1
+	}
+	for i := 0; i < iterations; i++ {
+		s.run(workerSignal{Pass: passCollide})
+		overlaps := 0
+		for _, w := range s.workers {
+			overlaps += w.overlaps
+		}
+		if overlaps == 0 {
+			return
+		}
+		s.run(workerSignal{Pass: passPush})
+	}
+}
+
+// Close stops the Swarm's workers. The Swarm can still be read from afterwards,
+// but Update must not be called again.
+func (s *Swarm) Close() {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	for _, w := range s.workers {
+		close(w.signal)
+	}
+}
+
+// run signals all workers to perform a pass and waits for them to finish.
+func (s *Swarm) run(sig workerSignal) {
+	s.wg.Add(len(s.workers))
+	for _, w := range s.workers {
+		w.signal <- sig
 	}
 	s.wg.Wait()
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
+type workerPass int
+
+const (
+	passUpdate  workerPass = iota // Update velocities (dirty) or positions.
+	passCollide                   // Find overlapping Boids.
+	passPush                      // Push apart overlapping Boids.
+)
+
 type workerSignal struct {
+	Pass   workerPass
 	Dirty  bool
 	Target Vector
 }
 
-func (s *Swarm) workerUpdate(boids []*Boid) {
+// Each worker has it's own signal channel, or else a fast worker could steal
+// another worker's signal and update it's own Boids twice.
+// Events are buffered per worker, so they can be delivered without any locking.
+type worker struct {
+	signal   chan workerSignal
+	boids    []*Boid
+	events   []Event
+	overlaps int
+	busy     time.Duration // Time spent on passes, since the last Update.
+	visited  uint64        // Neighbours visited, since the last Update.
+}
+
+func (w *worker) emit(e Event) {
+	w.events = append(w.events, e)
+}
+
+func (s *Swarm) workerUpdate(w *worker) {
 	for {
-		// TODO: check for termination signal so it can shut down cleanly?
-		sig := <-s.signal
-		for _, b := range boids {
-			s.updateBoid(b, sig.Dirty, sig.Target)
+		sig, ok := <-w.signal
+		if !ok {
+			return
+		}
+		start := time.Now()
+		switch sig.Pass {
+		case passUpdate:
+			for _, b := range w.boids {
+				if s.Conf.FixedPoint {
+					s.updateBoidFixed(w, b, sig.Dirty, sig.Target)
+				} else {
+					s.updateBoid(w, b, sig.Dirty, sig.Target)
+				}
+			}
+		case passCollide:
+			w.overlaps = 0
+			for _, b := range w.boids {
+				w.overlaps += s.collide(b)
+			}
+		case passPush:
+			for _, b := range w.boids {
+				b.Pos = b.Pos.Addv(b.push)
+			}
 		}
+		w.busy += time.Since(start)
 		s.wg.Done()
 	}
 }
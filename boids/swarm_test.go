@@ -1,18 +1,31 @@
 package boids
 
 import (
+	"math"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 )
 
+func mustNew(tb testing.TB, conf Conf) *Swarm {
+	s, err := New(conf)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return s
+}
+
 func BenchmarkBoids(b *testing.B) {
-	s := New(Conf{
+	s := mustNew(b, Conf{
 		Boids: 500,
 		Spawn: [2]Vector{
 			NewVector(0, 0),
 			NewVector(100, 100),
 		},
-		Seed:    0,
-		Workers: 10,
+		Seed:        0,
+		Workers:     10,
+		IndexOffset: 50,
 	})
 	v := NewVector(0, 0)
 	b.ResetTimer()
@@ -21,3 +34,317 @@ func BenchmarkBoids(b *testing.B) {
 		s.Update(i%2 == 0, v)
 	}
 }
+
+func TestTraits(t *testing.T) {
+	conf := Conf{
+		Boids:   100,
+		Workers: 10,
+		Spawn: [2]Vector{
+			NewVector(0, 0),
+			NewVector(100, 100),
+		},
+		IndexOffset: 50,
+		SpeedTrait:  Uniform(0.5, 1.5),
+		SocialTrait: Normal(1, 0.2),
+	}
+	s1, s2 := mustNew(t, conf), mustNew(t, conf)
+	for i, b := range s1.Boids {
+		if b.Traits != s2.Boids[i].Traits {
+			t.Fatalf("got traits %v, expected %v (same seed)", b.Traits, s2.Boids[i].Traits)
+		}
+		if b.Traits.Speed < 0.5 || b.Traits.Speed > 1.5 {
+			t.Errorf("got speed trait %f, expected within 0.5-1.5", b.Traits.Speed)
+		}
+		if b.Traits.Social < 0.39 || b.Traits.Social > 1.61 {
+			t.Errorf("got social trait %f, expected within 3 standard deviations", b.Traits.Social)
+		}
+		if b.Traits.Size != 1 {
+			t.Errorf("got size trait %f, expected 1 (no distribution)", b.Traits.Size)
+		}
+	}
+}
+
+func TestTurnRate(t *testing.T) {
+	s := mustNew(t, Conf{
+		Boids:   100,
+		Workers: 10,
+		Spawn: [2]Vector{
+			NewVector(0, 0),
+			NewVector(100, 100),
+		},
+		IndexOffset:      50,
+		CohesionFactor:   0.01,
+		AlignmentFactor:  0.05,
+		SeparationRange:  20,
+		SeparationFactor: 0.3,
+		VelocityMax:      1,
+		VelocityMin:      0.5,
+		MaxForce:         0.1,
+		MaxTurnRate:      0.1,
+	})
+	prev := make([]float64, len(s.Boids))
+	target := NewVector(50, 50)
+	for i := 0; i < 50; i++ {
+		s.Update(true, target)
+		for _, b := range s.Boids {
+			diff := math.Abs(math.Remainder(b.Heading-prev[b.ID], 2*math.Pi))
+			if diff > 0.1+1e-9 {
+				t.Fatalf("got heading change %f, expected max 0.1", diff)
+			}
+			if a := b.Vel.Angle(); math.Abs(math.Remainder(a-b.Heading, 2*math.Pi)) > 1e-9 {
+				t.Fatalf("got velocity angle %f, expected heading %f", a, b.Heading)
+			}
+			prev[b.ID] = b.Heading
+		}
+	}
+}
+
+func TestCollisions(t *testing.T) {
+	s := mustNew(t, Conf{
+		Boids:   200,
+		Workers: 10,
+		Spawn: [2]Vector{
+			NewVector(0, 0),
+			NewVector(100, 100),
+		},
+		IndexOffset:         50,
+		CohesionFactor:      0.01,
+		AlignmentFactor:     0.05,
+		VelocityMax:         1,
+		VelocityMin:         0.5,
+		BodyRadius:          2,
+		CollisionIterations: 50,
+		SizeTrait:           Uniform(0.5, 1.5),
+	})
+	target := NewVector(50, 50)
+	for i := 0; i < 20; i++ {
+		s.Update(i%2 == 0, target)
+		if i%2 == 0 {
+			continue
+		}
+		for _, a := range s.Boids {
+			for _, b := range s.Boids[a.ID+1:] {
+				min := 2 * (a.Traits.Size + b.Traits.Size)
+				if d := a.Pos.Subv(b.Pos).Length(); d < min-collisionSlop {
+					t.Fatalf("got distance %f between boid %d and %d, expected at least %f", d, a.ID, b.ID, min)
+				}
+			}
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := DefaultConf().Validate(); err != nil {
+		t.Fatalf("got error %q, expected default conf to be valid", err)
+	}
+	if _, err := New(DefaultConf()); err != nil {
+		t.Fatalf("got error %q, expected a new swarm", err)
+	}
+
+	conf := DefaultConf()
+	conf.Workers = 0
+	conf.IndexOffset = 0
+	conf.VelocityMax = -1
+	conf.Spawn[0] = NewVector(2000, 0)
+	conf.SizeTrait = Uniform(2, 1)
+	s, err := New(conf)
+	if s != nil || err == nil {
+		t.Fatalf("got swarm %v and error %v, expected only an error", s, err)
+	}
+	e, ok := err.(ConfError)
+	if !ok {
+		t.Fatalf("got error type %T, expected ConfError", err)
+	}
+	// VelocityMax also being smaller than VelocityMin adds an extra problem
+	if len(e) != 6 {
+		t.Errorf("got %d problems, expected 6:\n%s", len(e), e)
+	}
+
+	conf = DefaultConf()
+	conf.FixedPoint = true
+	conf.SpeedTrait = Uniform(0.8, 1.2)
+	conf.SizeTrait = Normal(1, 0.1)
+	if err := conf.Validate(); err == nil || !strings.Contains(err.Error(), "SizeTrait") {
+		t.Errorf("got error %v, expected a normal distribution to be rejected with FixedPoint", err)
+	}
+}
+
+func TestLeftoverBoids(t *testing.T) {
+	conf := DefaultConf()
+	conf.Boids = 10
+	conf.Workers = 3
+	s := mustNew(t, conf)
+	num := 0
+	for _, w := range s.workers {
+		num += len(w.boids)
+	}
+	if num != conf.Boids {
+		t.Errorf("got %d boids divided between the workers, expected %d", num, conf.Boids)
+	}
+}
+
+func TestSetConf(t *testing.T) {
+	s := mustNew(t, DefaultConf())
+	conf := DefaultConf()
+	conf.SeparationRange = 30
+	conf.Boids = 10
+	if err := s.SetConf(conf); err != nil {
+		t.Fatalf("got error %q, expected conf to be set", err)
+	}
+	if s.Conf.SeparationRange != 20 {
+		t.Errorf("got separation range %v, expected 20 until the next update", s.Conf.SeparationRange)
+	}
+	s.Update(true, NewVector(0, 0))
+	if s.Conf.SeparationRange != 30 || s.squareSeparationRange != 900 {
+		t.Errorf("got separation range %v, expected 30", s.Conf.SeparationRange)
+	}
+	if s.Conf.Boids != 500 {
+		t.Errorf("got %d boids, expected 500", s.Conf.Boids)
+	}
+
+	conf.VelocityMin = 10
+	if err := s.SetConf(conf); err == nil {
+		t.Error("got no error, expected invalid conf")
+	}
+	s.Update(false, NewVector(0, 0))
+	if s.Conf.VelocityMin != 0.5 {
+		t.Errorf("got min velocity %v, expected 0.5", s.Conf.VelocityMin)
+	}
+}
+
+func TestTune(t *testing.T) {
+	s := mustNew(t, DefaultConf())
+	done := make(chan bool)
+	go func() {
+		// Run with -race to check for data races
+		for i := 0; i < 100; i++ {
+			err := s.Tune(func(c *Conf) {
+				c.CohesionFactor += 0.001
+				c.IndexOffset = 30 + i%2*10
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}
+		done <- true
+	}()
+	for i := 0; i < 100; i++ {
+		s.Update(i%2 == 0, NewVector(0, 0))
+	}
+	<-done
+	s.Update(true, NewVector(0, 0))
+
+	if f := s.Conf.CohesionFactor; math.Abs(f-0.101) > 1e-9 {
+		t.Errorf("got cohesion factor %v, expected 0.101", f)
+	}
+	if s.Conf.IndexOffset != 40 || s.Index.offset != 40 {
+		t.Errorf("got index offset %v, expected 40", s.Index.offset)
+	}
+	num := 0
+	s.Index.IterBounds(NewVector(-1000, -1000), NewVector(3000, 3000), func(int) { num++ })
+	if num != len(s.Boids) {
+		t.Errorf("got %d boids in the index, expected %d", num, len(s.Boids))
+	}
+}
+
+func TestClose(t *testing.T) {
+	before := runtime.NumGoroutine()
+	s := mustNew(t, DefaultConf())
+	s.Update(true, NewVector(0, 0))
+	s.Close()
+	s.Close()
+	// Gives the workers a moment to exit
+	for i := 0; i < 100 && runtime.NumGoroutine() > before; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("got %d goroutines, expected %d", n, before)
+	}
+}
+
+func TestStats(t *testing.T) {
+	conf := DefaultConf()
+	s := mustNew(t, conf)
+	defer s.Close()
+	done := make(chan Stats)
+	go func() {
+		// Must be safe to read while updating
+		for i := 0; i < 10; i++ {
+			s.Stats()
+		}
+		done <- s.Stats()
+	}()
+	for i := 0; i < 10; i++ {
+		s.Update(i%2 == 0, NewVector(0, 0))
+	}
+	<-done
+
+	st := s.Stats()
+	if st.Updates != 10 || st.Dirty != 5 {
+		t.Errorf("got %d updates (%d dirty), expected 10 (5 dirty)", st.Updates, st.Dirty)
+	}
+	if len(st.Workers) != conf.Workers || st.Neighbours < uint64(conf.Boids) {
+		t.Errorf("got %d workers and %d neighbours, expected %d workers and more neighbours than boids",
+			len(st.Workers), st.Neighbours, conf.Workers)
+	}
+	if st.Index+st.Compute > st.Duration || st.Compute <= 0 {
+		t.Errorf("got index %s and compute %s, expected them within the total %s", st.Index, st.Compute, st.Duration)
+	}
+	for i := 1; i < len(st.Buckets); i++ {
+		if st.Buckets[i] < st.Buckets[i-1] || st.Buckets[i] > st.Updates {
+			t.Fatalf("got buckets %v, expected cumulative counts", st.Buckets)
+		}
+	}
+
+	// Position updates doesn't build the Index
+	s2 := mustNew(t, conf)
+	defer s2.Close()
+	for i := 0; i < 5; i++ {
+		s2.Update(false, NewVector(0, 0))
+	}
+	if st := s2.Stats(); st.Index != 0 || st.Compute <= 0 {
+		t.Errorf("got index %s and compute %s, expected no index and some compute", st.Index, st.Compute)
+	}
+}
+
+func TestInform(t *testing.T) {
+	s := mustNew(t, Conf{
+		Boids:       3,
+		Workers:     1,
+		IndexOffset: 50,
+		Spawn: [2]Vector{
+			NewVector(0, 0),
+			NewVector(100, 100),
+		},
+		VelocityMax: 1,
+		VelocityMin: 0.5,
+	})
+	point := NewVector(300, 200)
+	s.Inform(PointGoal(point, 1), 0)
+	s.Inform(DirectionGoal(NewVector(-1, 0), 0.5), 1)
+	roles := []Role{RoleInformed, RoleInformed, RoleNaive}
+	for i, b := range s.Boids {
+		if b.Role != roles[i] {
+			t.Errorf("got boid %d with role %d, expected %d", i, b.Role, roles[i])
+		}
+	}
+	if w := s.Boids[0].Goal.Weight; w != 1 {
+		t.Errorf("got weight %v for the first group, expected 1", w)
+	}
+	for i := 0; i < 2000; i++ {
+		s.Update(i%2 == 0, NewVector(0, 0))
+	}
+	if d := s.Boids[0].Pos.Subv(point).Length(); d > 5 {
+		t.Errorf("got distance %f to the point goal, expected at most 5", d)
+	}
+	if x := s.Boids[1].Vel.Normalize().X; x > -0.99 {
+		t.Errorf("got direction %f, expected -1", x)
+	}
+
+	s.Inform(Goal{})
+	for i, b := range s.Boids {
+		if b.Role != RoleDefault || b.Goal != (Goal{}) {
+			t.Errorf("got boid %d with role %d and goal %+v, expected the default role", i, b.Role, b.Goal)
+		}
+	}
+}
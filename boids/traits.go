@@ -0,0 +1,94 @@
+package boids
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+)
+
+// DistKind selects the kind of probability distribution a Distribution samples from.
+type DistKind int
+
+const (
+	DistNone    DistKind = iota // No variation, always samples 1.
+	DistUniform                 // Uniform distribution between A (min) and B (max).
+	DistNormal                  // Normal distribution with A (mean) and B (standard deviation).
+)
+
+var distNames = []string{"none", "uniform", "normal"}
+
+func (k DistKind) String() string {
+	if k < DistNone || k > DistNormal {
+		return fmt.Sprintf("DistKind(%d)", int(k))
+	}
+	return distNames[k]
+}
+
+// MarshalText encodes the kind by name, for use in config files.
+func (k DistKind) MarshalText() ([]byte, error) {
+	if k < DistNone || k > DistNormal {
+		return nil, fmt.Errorf("unknown distribution kind %d", int(k))
+	}
+	return []byte(distNames[k]), nil
+}
+
+// UnmarshalText decodes a kind by name, such as "uniform" or "normal".
+func (k *DistKind) UnmarshalText(text []byte) error {
+	for i, n := range distNames {
+		if strings.EqualFold(string(text), n) {
+			*k = DistKind(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown distribution kind '%s'", text)
+}
+
+// Distribution describes how a per-boid trait multiplier is randomised.
+// The zero value disables any variation.
+type Distribution struct {
+	Kind DistKind
+	A, B float64
+}
+
+// Uniform returns a Distribution sampling evenly between min and max.
+func Uniform(min, max float64) Distribution {
+	return Distribution{DistUniform, min, max}
+}
+
+// Normal returns a Distribution sampling around mean, with stddev as the standard deviation.
+func Normal(mean, stddev float64) Distribution {
+	return Distribution{DistNormal, mean, stddev}
+}
+
+// Sample returns a new random multiplier.
+// Normal samples are cut off at 3 standard deviations and can never go below 0,
+// as negative speeds or sizes wouldn't make any sense.
+// Only uniform samples are guaranteed to be the same on all architectures, so normal
+// distributions can't be used with Conf.FixedPoint.
+func (d Distribution) Sample(r *rand.Rand) float64 {
+	switch d.Kind {
+	case DistUniform:
+		return d.A + float64(r.Float64()*(d.B-d.A)) // Prevents FMA, see spawnPos
+	case DistNormal:
+		f := r.NormFloat64()
+		f = math.Max(-3, math.Min(3, f))
+		return math.Max(0, d.A+float64(f*d.B))
+	}
+	return 1
+}
+
+// Traits are multipliers that gives each Boid a little bit of personality.
+type Traits struct {
+	Speed  float64 // Scales VelocityMin and VelocityMax.
+	Size   float64 // Scales the size of the rendered Boid.
+	Social float64 // Scales the cohesion, alignment and separation forces.
+}
+
+func (c Conf) sampleTraits(r *rand.Rand) Traits {
+	return Traits{
+		Speed:  c.SpeedTrait.Sample(r),
+		Size:   c.SizeTrait.Sample(r),
+		Social: c.SocialTrait.Sample(r),
+	}
+}
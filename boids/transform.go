@@ -0,0 +1,94 @@
+package boids
+
+import (
+	"fmt"
+	"math"
+)
+
+// Transform2D is an affine transformation matrix, for translating, rotating and scaling Vectors.
+// It works the same way as ebiten's GeoM, but without depending on ebiten, so it can be used
+// for camera, sprite and obstacle math anywhere:
+//
+//	| A  B  TX |
+//	| C  D  TY |
+//	| 0  0  1  |
+//
+// The zero value is not usable, use IdentityTransform instead.
+type Transform2D struct {
+	A, B, TX float64
+	C, D, TY float64
+}
+
+// IdentityTransform returns a Transform2D that doesn't change anything.
+func IdentityTransform() Transform2D {
+	return Transform2D{A: 1, D: 1}
+}
+
+// Concat returns a Transform2D that applies t first, followed by other.
+func (t Transform2D) Concat(other Transform2D) Transform2D {
+	return Transform2D{
+		A:  other.A*t.A + other.B*t.C,
+		B:  other.A*t.B + other.B*t.D,
+		TX: other.A*t.TX + other.B*t.TY + other.TX,
+		C:  other.C*t.A + other.D*t.C,
+		D:  other.C*t.B + other.D*t.D,
+		TY: other.C*t.TX + other.D*t.TY + other.TY,
+	}
+}
+
+// Translate returns t followed by a translation.
+func (t Transform2D) Translate(x, y float64) Transform2D {
+	return t.Concat(Transform2D{A: 1, TX: x, D: 1, TY: y})
+}
+
+// Rotate returns t followed by a rotation with angle a (in radians), see Vector.Rotate.
+func (t Transform2D) Rotate(a float64) Transform2D {
+	sin, cos := math.Sincos(a)
+	return t.Concat(Transform2D{A: cos, B: -sin, C: sin, D: cos})
+}
+
+// Scale returns t followed by a scaling.
+func (t Transform2D) Scale(x, y float64) Transform2D {
+	return t.Concat(Transform2D{A: x, D: y})
+}
+
+// Determinant of the linear part of the matrix. It's 0 if the transform can't be inverted.
+func (t Transform2D) Determinant() float64 {
+	return t.A*t.D - t.B*t.C
+}
+
+// Inverse returns the Transform2D that undoes t, or false if t can't be inverted.
+func (t Transform2D) Inverse() (Transform2D, bool) {
+	det := t.Determinant()
+	if det == 0 {
+		return Transform2D{}, false
+	}
+	return Transform2D{
+		A:  t.D / det,
+		B:  -t.B / det,
+		TX: (t.B*t.TY - t.D*t.TX) / det,
+		C:  -t.C / det,
+		D:  t.A / det,
+		TY: (t.C*t.TX - t.A*t.TY) / det,
+	}, true
+}
+
+// Apply transforms a point.
+func (t Transform2D) Apply(v Vector) Vector {
+	return Vector{
+		t.A*v.X + t.B*v.Y + t.TX,
+		t.C*v.X + t.D*v.Y + t.TY,
+	}
+}
+
+// ApplyDir transforms a direction, ignoring any translation.
+func (t Transform2D) ApplyDir(v Vector) Vector {
+	return Vector{
+		t.A*v.X + t.B*v.Y,
+		t.C*v.X + t.D*v.Y,
+	}
+}
+
+func (t Transform2D) String() string {
+	return fmt.Sprintf("[%+0.3f %+0.3f %+0.3f; %+0.3f %+0.3f %+0.3f]", t.A, t.B, t.TX, t.C, t.D, t.TY)
+}
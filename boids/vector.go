@@ -14,6 +14,11 @@ func NewVector(x, y float64) Vector {
 	return Vector{x, y}
 }
 
+// Creates a new unit vector pointing in the direction of angle a (in radians).
+func FromAngle(a float64) Vector {
+	return Vector{math.Cos(a), math.Sin(a)}
+}
+
 // Calculates the vector angle and returns radians.
 // To get degrees: multiply radians with 180/Pi
 func (v Vector) Angle() float64 {
@@ -46,6 +51,97 @@ func (v Vector) InRange(r float64) float64 {
 	return 0
 }
 
+// Returns a unit vector with the same direction, or a zero vector if it has no length.
+func (v Vector) Normalize() Vector {
+	l := v.Length()
+	if l == 0 {
+		return v
+	}
+	return v.Div(l)
+}
+
+// Limits the vector length to max, while keeping it's direction.
+func (v Vector) Limit(max float64) Vector {
+	l := v.Dot(v)
+	if l > max*max {
+		return v.Mul(max / math.Sqrt(l))
+	}
+	return v
+}
+
+// Clamps the vector length between min and max, while keeping it's direction.
+// A zero vector stays zero, as it has no direction.
+func (v Vector) ClampLength(min, max float64) Vector {
+	l := v.Dot(v)
+	switch {
+	case l == 0:
+		return v
+	case l > max*max:
+		return v.Mul(max / math.Sqrt(l))
+	case l < min*min:
+		return v.Mul(min / math.Sqrt(l))
+	}
+	return v
+}
+
+// Rotates the vector by angle a (in radians), counter-clockwise in a Y-up system
+// (or clockwise on screen, where Y points down).
+func (v Vector) Rotate(a float64) Vector {
+	sin, cos := math.Sincos(a)
+	return Vector{v.X*cos - v.Y*sin, v.X*sin + v.Y*cos}
+}
+
+// Linearly interpolates between the vector (t = 0) and other (t = 1).
+func (v Vector) Lerp(other Vector, t float64) Vector {
+	return v.Addv(other.Subv(v).Mul(t))
+}
+
+// Calculates the distance between two points.
+func (v Vector) Distance(other Vector) float64 {
+	return math.Sqrt(v.DistanceSq(other))
+}
+
+// Calculates the squared distance between two points, which avoids the expensive square root.
+func (v Vector) DistanceSq(other Vector) float64 {
+	d := other.Subv(v)
+	return d.Dot(d)
+}
+
+// Returns the perpendicular vector, rotated by 90 degrees.
+func (v Vector) Perp() Vector {
+	return Vector{-v.Y, v.X}
+}
+
+// Calculates the 2D cross product (the Z component of the 3D cross product).
+// It's positive if other is counter-clockwise from the vector, in a Y-up system.
+func (v Vector) Cross(other Vector) float64 {
+	return v.X*other.Y - v.Y*other.X
+}
+
+// Reflects the vector off a surface with the normal n, which must be a unit vector.
+func (v Vector) Reflect(n Vector) Vector {
+	return v.Subv(n.Mul(2 * v.Dot(n)))
+}
+
+// Projects the vector onto other, or returns a zero vector if other has no length.
+func (v Vector) Project(other Vector) Vector {
+	l := other.Dot(other)
+	if l == 0 {
+		return Vector{}
+	}
+	return other.Mul(v.Dot(other) / l)
+}
+
+// Checks if two vectors are equal, within a margin of epsilon for each component.
+func (v Vector) ApproxEqual(other Vector, epsilon float64) bool {
+	return math.Abs(v.X-other.X) <= epsilon && math.Abs(v.Y-other.Y) <= epsilon
+}
+
+// Checks if any of the components are NaN (not a number).
+func (v Vector) IsNaN() bool {
+	return math.IsNaN(v.X) || math.IsNaN(v.Y)
+}
+
 // Checks if the current vector is within a bounding box.
 func (v Vector) Within(min, max Vector) bool {
 	return v.X >= min.X && v.Y >= min.Y && v.X <= max.X && v.Y <= max.Y
@@ -102,6 +102,108 @@ func TestSimpleOperations(t *testing.T) {
 	})
 }
 
+const epsilon float64 = 1e-9
+
+func TestVectorMath(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Vector
+		x, y float64
+	}{
+		{"from angle", FromAngle(pi / 2), 0, 1},
+		{"normalize", NewVector(3, 4).Normalize(), 0.6, 0.8},
+		{"normalize zero", NewVector(0, 0).Normalize(), 0, 0},
+		{"limit", NewVector(3, 4).Limit(2.5), 1.5, 2},
+		{"limit within", NewVector(3, 4).Limit(10), 3, 4},
+		{"clamp length max", NewVector(3, 4).ClampLength(1, 2.5), 1.5, 2},
+		{"clamp length min", NewVector(3, 4).ClampLength(10, 20), 6, 8},
+		{"clamp length zero", NewVector(0, 0).ClampLength(1, 2), 0, 0},
+		{"rotate", NewVector(1, 0).Rotate(pi / 2), 0, 1},
+		{"rotate back", NewVector(1, 2).Rotate(pi).Rotate(-pi), 1, 2},
+		{"lerp", NewVector(0, 0).Lerp(NewVector(10, 20), 0.25), 2.5, 5},
+		{"perp", NewVector(1, 2).Perp(), -2, 1},
+		{"reflect", NewVector(1, -1).Reflect(NewVector(0, 1)), 1, 1},
+		{"project", NewVector(2, 3).Project(NewVector(10, 0)), 2, 0},
+		{"project zero", NewVector(2, 3).Project(NewVector(0, 0)), 0, 0},
+	}
+	for _, tt := range tests {
+		if !tt.got.ApproxEqual(NewVector(tt.x, tt.y), epsilon) {
+			t.Errorf("%s: got vector %s, expected (%v, %v)", tt.name, tt.got, tt.x, tt.y)
+		}
+	}
+
+	floats := []struct {
+		name   string
+		got, e float64
+	}{
+		{"distance", NewVector(1, 1).Distance(NewVector(4, 5)), 5},
+		{"distance squared", NewVector(1, 1).DistanceSq(NewVector(4, 5)), 25},
+		{"cross", NewVector(1, 0).Cross(NewVector(0, 1)), 1},
+		{"cross reversed", NewVector(0, 1).Cross(NewVector(1, 0)), -1},
+	}
+	for _, tt := range floats {
+		if math.Abs(tt.got-tt.e) > epsilon {
+			t.Errorf("%s: got %v, expected %v", tt.name, tt.got, tt.e)
+		}
+	}
+
+	bools := []struct {
+		name   string
+		got, e bool
+	}{
+		{"approx equal", NewVector(1, 1).ApproxEqual(NewVector(1.05, 0.95), 0.1), true},
+		{"not approx equal", NewVector(1, 1).ApproxEqual(NewVector(1.2, 1), 0.1), false},
+		{"nan", NewVector(math.NaN(), 0).IsNaN(), true},
+		{"not nan", piv.IsNaN(), false},
+	}
+	for _, tt := range bools {
+		if tt.got != tt.e {
+			t.Errorf("%s: got %v, expected %v", tt.name, tt.got, tt.e)
+		}
+	}
+}
+
+func TestTransform2D(t *testing.T) {
+	v := NewVector(1, 2)
+	tests := []struct {
+		name string
+		tr   Transform2D
+		x, y float64
+	}{
+		{"identity", IdentityTransform(), 1, 2},
+		{"translate", IdentityTransform().Translate(10, 20), 11, 22},
+		{"scale", IdentityTransform().Scale(2, 3), 2, 6},
+		{"rotate", IdentityTransform().Rotate(pi / 2), -2, 1},
+		{"scale then translate", IdentityTransform().Scale(2, 2).Translate(1, 1), 3, 5},
+		{"translate then scale", IdentityTransform().Translate(1, 1).Scale(2, 2), 4, 6},
+		{"concat", IdentityTransform().Translate(1, 1).Concat(IdentityTransform().Rotate(pi)), -2, -3},
+	}
+	for _, tt := range tests {
+		got := tt.tr.Apply(v)
+		if !got.ApproxEqual(NewVector(tt.x, tt.y), epsilon) {
+			t.Errorf("%s: got vector %s, expected (%v, %v)", tt.name, got, tt.x, tt.y)
+		}
+		inv, ok := tt.tr.Inverse()
+		if !ok {
+			t.Errorf("%s: got no inverse for %s", tt.name, tt.tr)
+			continue
+		}
+		if back := inv.Apply(got); !back.ApproxEqual(v, epsilon) {
+			t.Errorf("%s: got inverted vector %s, expected %s", tt.name, back, v)
+		}
+	}
+
+	t.Run("apply direction", func(t *testing.T) {
+		d := IdentityTransform().Scale(2, 2).Translate(100, 100).ApplyDir(v)
+		assertVector(t, d, 2, 4)
+	})
+	t.Run("no inverse", func(t *testing.T) {
+		if _, ok := IdentityTransform().Scale(0, 1).Inverse(); ok {
+			t.Errorf("got an inverse, expected none for a zero scale")
+		}
+	})
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
 func BenchmarkVectors(b *testing.B) {
@@ -0,0 +1,294 @@
+// Command akvarium-headless runs the boids simulation without a display (or GPU),
+// for running experiments on servers and in CI.
+//
+//	go run ./cmd/akvarium-headless -preset baitball -steps 4000
+//	go run ./cmd/akvarium-headless -clip out.gif -frames 300
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lmas/akvarium/api"
+	"github.com/lmas/akvarium/boids"
+	"github.com/lmas/akvarium/boids/metrics"
+	"github.com/lmas/akvarium/monitor"
+	"github.com/lmas/akvarium/render"
+	"github.com/lmas/akvarium/utils"
+)
+
+var (
+	flagConfig = flag.String("config", "", "Load config from a JSON/TOML file (same format as the main simulation)")
+	flagPreset = flag.String("preset", "", "Use a named preset for the swarm (school, murmuration, insects or baitball), defaults to school")
+	flagLoad   = flag.String("load", "", "Start from a saved snapshot, instead of a new swarm (with any -config changes on top)")
+	flagSteps  = flag.Int("steps", 2000, "Number of updates to run")
+	flagRate   = flag.Int("rate", 0, "Updates per second, or 0 to run as fast as possible")
+	flagReport = flag.Int("report", 0, "Print flock metrics every n:th update, or 0 for only at the end")
+	flagJSON   = flag.Bool("json", false, "Print the results as JSON")
+	flagSave   = flag.String("save", "", "Save a snapshot at the end (binary, or JSON if ending with .json)")
+	flagExport = flag.String("export", "", "Export trajectories to a file (.csv or .jsonl)")
+	flagEvery  = flag.Uint64("every", 2, "Only export every n:th update")
+	flagPNG    = flag.String("png", "", "Render PNG frames into a directory, using the software renderer")
+	flagFrames = flag.Int("frame-every", 10, "Only render every n:th update")
+	flagClip   = flag.String("clip", "", "Render an animated clip of the last updates, using the software renderer (.gif, .png or .apng)")
+	flagLength = flag.Int("frames", 300, "Number of frames in the clip, rendered every second update (at 30 frames per second)")
+	flagSVG    = flag.String("svg", "", "Draw the boids and their trails as an SVG diagram at the end")
+	flagTrail  = flag.Int("trail", 60, "Number of positions kept in each trail of the SVG diagram, or 0 for all")
+	flagShape  = flag.String("shape", "arrow", "Shape of the boids in the SVG diagram (arrow or triangle)")
+	flagHTTP   = flag.String("http", "", "Serve a HTTP API for observing and controlling the simulation, on an address like :8080")
+	flagSprite = flag.String("sprite", "assets/boid-clownfish.png", "Sprite used for rendering the boids")
+	flagWidth  = flag.Int("width", 1280, "Width of rendered frames")
+	flagHeight = flag.Int("height", 720, "Height of rendered frames")
+)
+
+// The clip is rendered every n:th update, assuming 60 updates per second like the main simulation.
+const clipEvery int = 2
+const clipFPS int = 30
+
+// How often a paused simulation checks for new API commands.
+const pauseInterval = 10 * time.Millisecond
+
+// Result is printed after the run.
+type Result struct {
+	Steps    int
+	Duration time.Duration
+	PerSec   float64 // Updates per second.
+	Metrics  metrics.Metrics
+}
+
+func main() {
+	flag.Parse()
+	log.SetFlags(0)
+
+	swarm, err := utils.LoadSwarm(*flagPreset, *flagConfig, *flagLoad)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer swarm.Close()
+
+	var exporter *boids.Exporter
+	if *flagExport != "" {
+		format, err := boids.ParseExportFormat(strings.TrimPrefix(filepath.Ext(*flagExport), "."))
+		if err != nil {
+			log.Fatal(err)
+		}
+		f, err := os.Create(*flagExport)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		exporter = boids.NewExporter(f, format)
+		exporter.Every = *flagEvery
+	}
+
+	var renderer *render.Renderer
+	var frame *image.RGBA
+	if *flagPNG != "" || *flagClip != "" {
+		sprite, err := render.LoadSprite(*flagSprite)
+		if err != nil {
+			log.Fatal(err)
+		}
+		renderer = render.New(*flagWidth, *flagHeight, sprite)
+		frame = image.NewRGBA(image.Rect(0, 0, *flagWidth, *flagHeight))
+	}
+	if *flagPNG != "" {
+		if err := os.MkdirAll(*flagPNG, 0o755); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// The clip covers the last updates, so the swarm has time to settle first
+	var clip render.Encoder
+	clipStart := *flagSteps
+	if *flagClip != "" {
+		if *flagLength < 1 {
+			log.Fatal("must have at least 1 frame in the clip")
+		}
+		if *flagSteps < *flagLength*clipEvery {
+			*flagSteps = *flagLength * clipEvery
+		}
+		clipStart = *flagSteps - *flagLength*clipEvery
+		f, err := os.Create(*flagClip)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		if clip, err = render.NewEncoder(f, filepath.Ext(*flagClip), *flagLength, clipFPS); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var svg *render.SVG
+	if *flagSVG != "" {
+		svg = render.NewSVG(swarm.Conf.Spawn[0], swarm.Conf.Spawn[1])
+		svg.Trail = *flagTrail
+		switch *flagShape {
+		case "arrow":
+		case "triangle":
+			svg.Shape = render.ShapeTriangle
+		default:
+			log.Fatalf("unknown shape '%s', expected arrow or triangle", *flagShape)
+		}
+	}
+
+	var srv *api.Server
+	var mon *monitor.Swarm
+	if *flagHTTP != "" {
+		srv = api.New(swarm)
+		reg := monitor.NewRegistry()
+		mon = monitor.NewSwarm(reg, swarm)
+		srv.Handle("/metrics", reg)
+		go func() {
+			log.Fatal(http.ListenAndServe(*flagHTTP, srv))
+		}()
+	}
+
+	var limit <-chan time.Time
+	if *flagRate > 0 {
+		t := time.NewTicker(time.Second / time.Duration(*flagRate))
+		defer t.Stop()
+		limit = t.C
+	}
+
+	target := swarm.Conf.Spawn[0].Addv(swarm.Conf.Spawn[1]).Div(2)
+	if svg != nil {
+		svg.Targets = []boids.Vector{target}
+		// Shows the loaded snapshot when there's no updates
+		if *flagSteps < 1 {
+			svg.Add(swarm)
+		}
+	}
+	var busy time.Duration
+	for i := 0; i < *flagSteps; i++ {
+		if limit != nil {
+			<-limit
+		}
+		t := target
+		if srv != nil {
+			for srv.Process(); !srv.Running(); srv.Process() {
+				time.Sleep(pauseInterval)
+			}
+			t = srv.Target(target)
+		}
+		start := time.Now()
+		// Must alternate between updating velocity (dirty) and position (non-dirty)
+		dirty := swarm.Tick()%2 == 0
+		swarm.Update(dirty, t)
+		busy += time.Since(start)
+		if srv != nil {
+			srv.Updated()
+			mon.Update(dirty)
+		}
+
+		if exporter != nil {
+			if err := exporter.Write(swarm); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if *flagPNG != "" && *flagFrames > 0 && (i+1)%*flagFrames == 0 {
+			// Assumes the same time step as the main simulation, running 60 updates per second
+			renderer.Draw(frame, swarm, float64(swarm.Tick())/60)
+			if err := savePNG(filepath.Join(*flagPNG, fmt.Sprintf("frame-%06d.png", swarm.Tick())), frame); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if svg != nil && swarm.Tick()%2 == 0 {
+			// Positions are only updated every second update
+			svg.Add(swarm)
+		}
+		if clip != nil && i >= clipStart && (i-clipStart)%clipEvery == 0 {
+			renderer.Draw(frame, swarm, float64(swarm.Tick())/60)
+			if err := clip.AddFrame(frame); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if *flagReport > 0 && (i+1)%*flagReport == 0 && i+1 < *flagSteps {
+			report(Result{i + 1, busy, perSec(i+1, busy), metrics.Compute(swarm)})
+		}
+	}
+
+	if exporter != nil {
+		if err := exporter.Flush(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if clip != nil {
+		if err := clip.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if svg != nil {
+		if err := saveSVG(*flagSVG, svg); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *flagSave != "" {
+		if err := utils.SaveSnapshot(*flagSave, swarm.Snapshot()); err != nil {
+			log.Fatal(err)
+		}
+	}
+	report(Result{*flagSteps, busy, perSec(*flagSteps, busy), metrics.Compute(swarm)})
+}
+
+func savePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func saveSVG(path string, svg *render.SVG) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := svg.Encode(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func perSec(steps int, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(steps) / d.Seconds()
+}
+
+func report(r Result) {
+	if *flagJSON {
+		b, err := json.Marshal(r)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(string(b))
+		return
+	}
+	m := r.Metrics
+	if r.Steps > 0 {
+		fmt.Printf("steps %d in %s (%0.f updates/s, %0.3f ms/update)\n",
+			r.Steps, r.Duration.Round(time.Millisecond), r.PerSec,
+			float64(r.Duration.Microseconds())/1000/float64(r.Steps),
+		)
+	} else {
+		fmt.Println("steps 0")
+	}
+	fmt.Printf("  boids %d  polarization %0.2f  milling %0.2f  nearest %0.1f  radius %0.f  density %0.4f  speed %0.2f ± %0.2f\n",
+		m.Boids, m.Polarization, m.Milling, m.NearestNeighbour, m.Radius, m.Density, m.Speed.Mean, m.Speed.StdDev,
+	)
+}
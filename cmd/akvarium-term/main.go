@@ -0,0 +1,137 @@
+// Command akvarium-term runs the aquarium in a terminal, drawing the boids with text and
+// ANSI escape codes. It needs no GPU, so it can run over SSH or in a tmux pane.
+//
+//	go run ./cmd/akvarium-term -braille -colours 256
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lmas/akvarium/term"
+	"github.com/lmas/akvarium/utils"
+)
+
+var (
+	flagConfig  = flag.String("config", "", "Load config from a JSON/TOML file (same format as the main simulation)")
+	flagPreset  = flag.String("preset", "", "Use a named preset for the swarm (school, murmuration, insects or baitball), defaults to school")
+	flagLoad    = flag.String("load", "", "Start from a saved snapshot, instead of a new swarm (with any -config changes on top)")
+	flagInit    = flag.Int("init", 2000, "Run initial updates to prime the simulation")
+	flagBraille = flag.Bool("braille", false, "Draw boids as braille dots, for a higher resolution")
+	flagColours = flag.String("colours", "true", "Colour depth (none, 256 or true)")
+	flagFPS     = flag.Int("fps", 30, "Frames drawn per second")
+	flagSize    = flag.String("size", "", "Size of the grid as 'COLSxROWS', instead of the terminal's size")
+)
+
+// Updates per second, like the main simulation.
+const updatesPerSec int = 60
+
+// How often the terminal's size is checked for changes.
+const resizeInterval = time.Second
+
+func main() {
+	flag.Parse()
+	log.SetFlags(0)
+
+	colours, err := term.ParseColours(*flagColours)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *flagFPS < 1 || *flagFPS > updatesPerSec {
+		log.Fatalf("fps must be between 1 and %d", updatesPerSec)
+	}
+
+	swarm, err := utils.LoadSwarm(*flagPreset, *flagConfig, *flagLoad)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer swarm.Close()
+
+	world := swarm.Conf.Spawn
+	target := world[0].Addv(world[1]).Div(2)
+	if *flagLoad == "" {
+		for i := 0; i < *flagInit; i++ {
+			// Must alternate between updating velocity (dirty) and position (non-dirty)
+			swarm.Update(swarm.Tick()%2 == 0, target)
+		}
+	}
+
+	cols, rows, err := size()
+	if err != nil {
+		log.Fatal(err)
+	}
+	t := term.New(cols, rows, world[0], world[1])
+	t.Colours = colours
+	if *flagBraille {
+		t.Mode = term.ModeBraille
+	}
+
+	out := bufio.NewWriterSize(os.Stdout, 1<<16)
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	update := time.NewTicker(time.Second / time.Duration(updatesPerSec))
+	defer update.Stop()
+	resize := time.NewTicker(resizeInterval)
+	defer resize.Stop()
+
+	frameEvery := uint64(updatesPerSec / *flagFPS)
+	for {
+		select {
+		case <-quit:
+			t.Restore(out)
+			out.Flush()
+			return
+		case <-resize.C:
+			if c, r, err := size(); err == nil && (c != cols || r != rows) {
+				cols, rows = c, r
+				t.Resize(cols, rows)
+			}
+		case <-update.C:
+			swarm.Update(swarm.Tick()%2 == 0, target)
+			if swarm.Tick()%frameEvery != 0 {
+				continue
+			}
+			t.Render(swarm)
+			if err := t.Draw(out); err != nil {
+				log.Fatal(err)
+			}
+			if err := out.Flush(); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+}
+
+// size returns the size of the grid, from -size or else the terminal's size.
+func size() (int, int, error) {
+	var cols, rows int
+	if *flagSize != "" {
+		if _, err := fmt.Sscanf(*flagSize, "%dx%d", &cols, &rows); err != nil {
+			return 0, 0, fmt.Errorf("bad size '%s': %s", *flagSize, err)
+		}
+		if cols < 1 || rows < 1 {
+			return 0, 0, fmt.Errorf("bad size '%s'", *flagSize)
+		}
+		return cols, rows, nil
+	}
+
+	// Asks stty, to avoid depending on platform specific syscalls
+	cmd := exec.Command("stty", "size")
+	cmd.Stdin = os.Stdin
+	b, err := cmd.Output()
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not get the terminal's size (try -size): %s", err)
+	}
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(b)), "%d %d", &rows, &cols); err != nil {
+		return 0, 0, fmt.Errorf("could not get the terminal's size (try -size): %s", err)
+	}
+	return cols, rows, nil
+}
@@ -2,7 +2,10 @@ module github.com/lmas/akvarium
 
 go 1.18
 
-require github.com/hajimehoshi/ebiten/v2 v2.3.7
+require (
+	github.com/BurntSushi/toml v1.2.1
+	github.com/hajimehoshi/ebiten/v2 v2.3.7
+)
 
 require (
 	github.com/go-gl/glfw/v3.3/glfw v0.0.0-20220806181222-55e207c401ad // indirect
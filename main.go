@@ -8,13 +8,20 @@ import (
 	"image"
 	"image/color"
 	_ "image/png"
+	"io"
 	"log"
-	"math"
+	"net/http"
+	"os"
+	"sync"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/lmas/akvarium/api"
 	"github.com/lmas/akvarium/boids"
+	"github.com/lmas/akvarium/monitor"
+	"github.com/lmas/akvarium/render"
 	"github.com/lmas/akvarium/utils"
 )
 
@@ -33,7 +40,15 @@ const Banner string = `
                                 #;;;oo#      `
 
 var (
+	flagConfig  = flag.String("config", "", "Load config from a JSON/TOML file, that is reloaded when changed")
 	flagInit    = flag.Int("init", 2000, "Run initial updates to prime the simulation")
+	flagLoad    = flag.String("load", "", "Resume from a saved snapshot (binary, or JSON if ending with .json), with any -config changes on top")
+	flagSave    = flag.String("save", "", "Save a snapshot on quit (binary, or JSON if ending with .json)")
+	flagRecord  = flag.String("record", "", "Record all input to a file, that can be replayed exactly")
+	flagReplay  = flag.String("replay", "", "Replay a recording, instead of running a new simulation")
+	flagHTTP    = flag.String("http", "", "Serve a HTTP API for observing and controlling the simulation, on an address like :8080")
+	flagClip    = flag.String("clip-format", "gif", "Format of clips recorded with the G key (gif or apng), drawn with the software renderer so they differ slightly from the window")
+	flagPreset  = flag.String("preset", "", "Use a named preset for the swarm (school, murmuration, insects or baitball), defaults to school")
 	flagProfile = flag.Bool("profile", false, "Perform a CPU/MEM profile and exit after 30 seconds")
 	flagVerbose = flag.Bool("verbose", false, "Toggle verbose info")
 	flagVersion = flag.Bool("version", false, "Print version and exit")
@@ -53,39 +68,105 @@ func main() {
 		ScreenWidth:   1280,
 		ScreenHeight:  720,
 		UpdatesPerSec: 10,
-		Swarm: boids.Conf{
-			Seed:                0,
-			Boids:               500,
-			Workers:             10,
-			IndexOffset:         50,
-			CohesionFactor:      0.001,
-			AlignmentFactor:     0.05,
-			SeparationRange:     20,
-			SeparationFactor:    0.3,
-			TargetRange:         50,
-			TargetRepelFactor:   0.3,
-			TargetAttractFactor: 0.00004,
-			VelocityMax:         1,
-			VelocityMin:         0.5,
-		},
+	}
+	if *flagConfig != "" {
+		if err := utils.LoadConfig(*flagConfig, &conf); err != nil {
+			log.Fatal(err)
+		}
+		conf.Verbose = conf.Verbose || *flagVerbose
+	}
+
+	if *flagHTTP != "" && *flagReplay != "" {
+		log.Fatal("can't control a replay with the HTTP API")
+	}
+	if *flagClip != "gif" && *flagClip != "apng" {
+		log.Fatalf("unknown clip format '%s', expected gif or apng", *flagClip)
 	}
 
 	if *flagProfile {
 		go utils.RunProfiler(".stats/cpu", ".stats/mem", 30)
 	}
 
-	s, err := New(conf)
+	var swarm *boids.Swarm
+	var player *boids.Player
+	fresh := false // Only new swarms are primed with initial updates.
+	if *flagReplay != "" {
+		f, err := os.Open(*flagReplay)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		if player, err = boids.NewPlayer(f); err != nil {
+			log.Fatalf("could not replay '%s': %s", *flagReplay, err)
+		}
+		swarm = player.Swarm()
+	} else {
+		load := *flagLoad
+		if _, err := os.Stat(load); err != nil && load == *flagSave {
+			// Starts a new simulation on the first run, when using the same file for -load and -save
+			load = ""
+		}
+		var err error
+		if swarm, err = utils.LoadSwarm(*flagPreset, *flagConfig, load); err != nil {
+			log.Fatal(err)
+		}
+		fresh = load == ""
+	}
+
+	s, err := New(conf, swarm)
 	if err != nil {
-		panic(err)
+		log.Fatal(err)
+	}
+	s.player = player
+	if *flagConfig != "" {
+		if err := s.Watch(*flagConfig); err != nil {
+			log.Fatal(err)
+		}
 	}
 
-	if !*flagProfile && *flagInit > 0 {
+	if !*flagProfile && *flagInit > 0 && fresh {
 		s.Init(*flagInit)
 	}
 
+	if *flagHTTP != "" {
+		s.api = api.New(s.swarm)
+		reg := monitor.NewRegistry()
+		s.monitor = monitor.NewSwarm(reg, s.swarm)
+		reg.Gauge("akvarium_fps", "Frames drawn per second.", ebiten.CurrentFPS)
+		s.api.Handle("/metrics", reg)
+		go func() {
+			log.Fatal(http.ListenAndServe(*flagHTTP, s.api))
+		}()
+		s.Log("Serving HTTP API on %s", *flagHTTP)
+	}
+
+	if *flagRecord != "" {
+		f, err := os.Create(*flagRecord)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		if err := s.swarm.Record(f, recordHashEvery); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	if err := s.Run(); err != nil {
 		panic(err)
 	}
+	s.StopClip()
+	s.clips.Wait()
+	if *flagRecord != "" {
+		if err := s.swarm.StopRecording(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *flagSave != "" {
+		s.Log("Saving snapshot..")
+		if err := utils.SaveSnapshot(*flagSave, s.swarm.Snapshot()); err != nil {
+			log.Fatal(err)
+		}
+	}
 }
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
@@ -108,13 +189,29 @@ type Simulation struct {
 	screen boids.Vector
 	target boids.Vector
 	tick   *utils.Ticker
+
+	config  string
+	watcher *utils.Watcher
+	player  *boids.Player
+	api     *api.Server
+	monitor *monitor.Swarm
+
+	tracker  *boids.ClusterTracker
+	clusters boids.Clusters
+	colours  bool
+
+	sprite   image.Image
+	clip     *render.Clip
+	clipFrom time.Time
+	clips    sync.WaitGroup // Clips being encoded in the background.
 }
 
 //go:embed assets/boid-clownfish.png
 //go:embed assets/shader.go
 var assets embed.FS
 
-func New(conf SimConf) (*Simulation, error) {
+// New creates a new Simulation, using an already existing swarm if it's not nil.
+func New(conf SimConf, swarm *boids.Swarm) (*Simulation, error) {
 	if conf.Swarm.Spawn[0].Length() == 0 && conf.Swarm.Spawn[1].Length() == 0 {
 		conf.Swarm.Spawn = [2]boids.Vector{
 			boids.NewVector(0, 0),
@@ -122,9 +219,18 @@ func New(conf SimConf) (*Simulation, error) {
 		}
 	}
 
+	if swarm != nil {
+		conf.Swarm = swarm.CurrentConf()
+	} else {
+		var err error
+		if swarm, err = boids.New(conf.Swarm); err != nil {
+			return nil, err
+		}
+	}
+
 	s := &Simulation{
 		Conf:  conf,
-		swarm: boids.New(conf.Swarm),
+		swarm: swarm,
 		op: &ebiten.DrawImageOptions{
 			Filter: ebiten.FilterLinear,
 		},
@@ -136,8 +242,9 @@ func New(conf SimConf) (*Simulation, error) {
 				},
 			},
 		},
-		screen: boids.NewVector(float64(conf.ScreenWidth), float64(conf.ScreenHeight)),
-		tick:   utils.NewTicker(ebiten.MaxTPS(), conf.UpdatesPerSec),
+		screen:  boids.NewVector(float64(conf.ScreenWidth), float64(conf.ScreenHeight)),
+		tick:    utils.NewTicker(ebiten.MaxTPS(), conf.UpdatesPerSec),
+		tracker: boids.NewClusterTracker(float64(conf.Swarm.IndexOffset), 5),
 	}
 	s.Log("Loading assets..")
 
@@ -146,6 +253,7 @@ func New(conf SimConf) (*Simulation, error) {
 		return nil, err
 	}
 	s.boid = ebiten.NewImageFromImage(sprite)
+	s.sprite = sprite
 
 	b, err := assets.ReadFile("assets/shader.go")
 	if err != nil {
@@ -164,6 +272,34 @@ func (s *Simulation) Log(msg string, args ...interface{}) {
 	}
 }
 
+// How often the config file is checked for changes.
+const watchInterval = time.Second
+
+// Watch starts watching the config file and applies any changes to the swarm
+// live, see boids.Swarm.SetConf. Other changes requires a restart.
+func (s *Simulation) Watch(path string) error {
+	w, err := utils.NewWatcher(path, watchInterval)
+	if err != nil {
+		return err
+	}
+	s.config, s.watcher = path, w
+	return nil
+}
+
+func (s *Simulation) reload() {
+	conf := s.Conf
+	if err := utils.LoadConfig(s.config, &conf); err != nil {
+		log.Printf("Reload failed: %s\n", err)
+		return
+	}
+	if err := s.swarm.SetConf(conf.Swarm); err != nil {
+		log.Printf("Reload failed: %s\n", err)
+		return
+	}
+	s.Conf.Swarm = s.swarm.CurrentConf()
+	s.Log("Reloaded config from %s", s.config)
+}
+
 func (s *Simulation) Init(simulationSteps int) {
 	s.Log("Priming simulation..")
 	t := s.screen.Div(2)
@@ -196,34 +332,160 @@ func (s *Simulation) Layout(width, height int) (int, int) {
 
 var errQuit = errors.New("quit")
 
+// Number of updates between each state hash in recordings.
+const recordHashEvery int = 100
+
+// Radius (in pixels) around the cursor that startles boids when clicking.
+const tapRadius float64 = 60
+
 func (s *Simulation) Update() error {
 	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) || inpututil.IsKeyJustPressed(ebiten.KeyQ) {
 		return errQuit
 	} else if inpututil.IsKeyJustPressed(ebiten.KeyF) {
 		ebiten.SetFullscreen(!ebiten.IsFullscreen())
+	} else if inpututil.IsKeyJustPressed(ebiten.KeyC) {
+		s.colours = !s.colours
+	} else if inpututil.IsKeyJustPressed(ebiten.KeyG) {
+		if s.clip == nil {
+			s.StartClip()
+		} else {
+			s.StopClip()
+		}
+	}
+	if s.player != nil {
+		err := s.replay()
+		s.capture()
+		return err
+	}
+	if s.watcher != nil && s.watcher.Changed() {
+		s.reload()
+	}
+	if s.api != nil {
+		s.api.Process()
+		if !s.api.Running() {
+			return nil
+		}
+	}
+
+	cx, cy := ebiten.CursorPosition()
+	cur := boids.NewVector(float64(cx), float64(cy))
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) && cur.Within(minVec, s.screen) {
+		// Tap on the glass
+		s.swarm.Startle(cur, tapRadius)
 	}
 
 	s.tick.Tick()
 	dirty := s.tick.Mod(1) == 0
 	if dirty {
-		cx, cy := ebiten.CursorPosition()
-		cur := boids.NewVector(float64(cx), float64(cy))
 		if cur.Within(minVec, s.screen) {
 			s.target = cur
 		} else {
 			s.target = s.screen.Div(2)
 		}
 	}
-	s.swarm.Update(dirty, s.target)
+	target := s.target
+	if s.api != nil {
+		target = s.api.Target(target)
+	}
+	s.swarm.Update(dirty, target)
+	if s.api != nil {
+		s.api.Updated()
+		s.monitor.Update(dirty)
+	}
+	if dirty && s.colours {
+		s.clusters, _ = s.tracker.Update(s.swarm)
+	}
+	s.capture()
+	return nil
+}
+
+// replay plays back one update from the recording, ignoring any user input.
+func (s *Simulation) replay() error {
+	err := s.player.Step()
+	if errors.Is(err, io.EOF) {
+		s.Log("Replay finished")
+		return errQuit
+	} else if err != nil {
+		return err
+	}
+	s.tick.Tick()
+	if s.colours && s.swarm.Tick()%2 == 0 {
+		s.clusters, _ = s.tracker.Update(s.swarm)
+	}
 	return nil
 }
 
-// https://www.color-name.com/light-ocean-blue.color
-var colBG = color.RGBA{0x04, 0x78, 0x9B, 0xFF}
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+// CLIPS
+
+// Clips are captured every second update, at 30 frames per second, for max 30 seconds.
+const clipEvery uint64 = 2
+const clipFPS int = 30
+const clipMaxFrames int = 30 * clipFPS
+
+// StartClip starts recording a clip, until StopClip is called.
+// As the GPU's frames can't be read back quickly (ebiten v2.3 has no ReadPixels), only the Boids'
+// poses are recorded and the clip is rendered with the software renderer afterwards.
+// It won't look exactly like the window, as the shader is a CPU port and cluster colours aren't drawn.
+func (s *Simulation) StartClip() {
+	s.Log("Recording clip..")
+	s.clip = &render.Clip{}
+	s.clipFrom = time.Now()
+}
+
+// StopClip stops recording and renders the clip to a new file in the background.
+func (s *Simulation) StopClip() {
+	if s.clip == nil {
+		return
+	}
+	clip := s.clip
+	s.clip = nil
+	if clip.Len() < 1 {
+		return
+	}
+	ext := "gif"
+	if *flagClip == "apng" {
+		ext = "png"
+	}
+	path := fmt.Sprintf("akvarium-%s.%s", s.clipFrom.Format("20060102-150405"), ext)
+	log.Printf("Rendering clip with %d frames to %s..\n", clip.Len(), path)
+	r := render.New(s.Conf.ScreenWidth, s.Conf.ScreenHeight, s.sprite)
+	s.clips.Add(1)
+	go func() {
+		defer s.clips.Done()
+		if err := clip.Save(path, r, clipFPS); err != nil {
+			log.Printf("Clip failed: %s\n", err)
+			return
+		}
+		log.Printf("Saved clip to %s\n", path)
+	}()
+}
+
+func (s *Simulation) capture() {
+	if s.clip == nil || s.swarm.Tick()%clipEvery != 0 {
+		return
+	}
+	s.clip.Add(s.swarm, s.tick.Float64())
+	if s.clip.Len() >= clipMaxFrames {
+		s.StopClip()
+	}
+}
+
+var colBG = render.Background
 
 // This prevents pop-in of boids at the top of the screen.
 var minVec = boids.NewVector(-1, -1)
 
+// Tints used for telling separate schools apart.
+var colClusters = []color.RGBA{
+	{0xFF, 0x60, 0x60, 0xFF},
+	{0x60, 0xFF, 0x60, 0xFF},
+	{0x60, 0x60, 0xFF, 0xFF},
+	{0xFF, 0xFF, 0x60, 0xFF},
+	{0xFF, 0x60, 0xFF, 0xFF},
+	{0x60, 0xFF, 0xFF, 0xFF},
+}
+
 func (s *Simulation) Draw(screen *ebiten.Image) {
 	screen.Fill(colBG)
 	if s.Conf.Verbose {
@@ -232,9 +494,13 @@ func (s *Simulation) Draw(screen *ebiten.Image) {
 
 	s.swarm.Index.IterBounds(minVec, s.screen, func(n int) {
 		b := s.swarm.Boids[n]
-		rotateAndTranslate(b.Pos, b.Vel.Angle(), s.boid, s.op)
+		rotateAndTranslate(b.Pos, b.Heading, b.Traits.Size, s.boid, s.op)
+		if s.colours && n < len(s.clusters.IDs) {
+			s.op.ColorM.ScaleWithColor(colClusters[s.clusters.IDs[n]%len(colClusters)])
+		}
 		screen.DrawImage(s.boid, s.op)
 		s.op.GeoM.Reset()
+		s.op.ColorM.Reset()
 	})
 
 	s.sop.Uniforms["Time"] = s.tick.Float32()
@@ -257,37 +523,13 @@ func loadImg(p string) (image.Image, error) {
 	return i, nil
 }
 
-const maxAngleN float64 = -math.Pi / 2
-const maxAngleNE float64 = -math.Pi / 4
-const maxAngleSE float64 = math.Pi / 4
-const maxAngleS float64 = math.Pi / 2
-
-func clampAngleAndFlip(a float64) (float64, bool) {
-	flipped := false
-	if a < maxAngleN {
-		a += math.Pi
-		flipped = true
-	} else if a > maxAngleS {
-		a -= math.Pi
-		flipped = true
-	}
-	if a > maxAngleN && a < maxAngleNE {
-		a = maxAngleNE
-	} else if a < maxAngleS && a > maxAngleSE {
-		a = maxAngleSE
-	}
-	return a, flipped
-}
-
-func rotateAndTranslate(pos boids.Vector, angle float64, src *ebiten.Image, op *ebiten.DrawImageOptions) {
+func rotateAndTranslate(pos boids.Vector, angle, size float64, src *ebiten.Image, op *ebiten.DrawImageOptions) {
 	x, y := src.Size()
-	w, h := float64(x), float64(y)
-	a, flipped := clampAngleAndFlip(angle)
-	if flipped {
-		op.GeoM.Scale(-1, 1)
-		op.GeoM.Translate(w, 0)
-	}
-	op.GeoM.Translate(-w/2, -h/2)
-	op.GeoM.Rotate(a)
-	op.GeoM.Translate(pos.X, pos.Y)
+	t := render.SpriteTransform(pos, angle, size, float64(x), float64(y))
+	op.GeoM.SetElement(0, 0, t.A)
+	op.GeoM.SetElement(0, 1, t.B)
+	op.GeoM.SetElement(0, 2, t.TX)
+	op.GeoM.SetElement(1, 0, t.C)
+	op.GeoM.SetElement(1, 1, t.D)
+	op.GeoM.SetElement(1, 2, t.TY)
 }
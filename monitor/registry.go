@@ -0,0 +1,185 @@
+// Package monitor exports the simulation's performance as metrics in the Prometheus text format,
+// so a long running aquarium can be scraped and alerted on when it starts to slow down.
+package monitor
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the Prometheus text format served by Registry.
+const ContentType string = "text/plain; version=0.0.4; charset=utf-8"
+
+type metric struct {
+	name, help, kind string
+	write            func(w *bufio.Writer, name string)
+}
+
+// Registry is a set of metrics, which are read from callbacks each time they're scraped.
+// It's a http.Handler serving the metrics in the Prometheus text format.
+type Registry struct {
+	mu      sync.Mutex // Serialises scrapes, so the callbacks doesn't have to be concurrency-safe with each other.
+	metrics []metric
+	names   map[string]bool
+	hooks   []func()
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		names: make(map[string]bool),
+	}
+}
+
+func (r *Registry) add(name, help, kind string, write func(w *bufio.Writer, name string)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[name] {
+		panic("monitor: duplicate metric " + name)
+	}
+	r.names[name] = true
+	r.metrics = append(r.metrics, metric{name, help, kind, write})
+}
+
+// OnScrape adds a func that's called before reading the metrics, on each scrape.
+// It's useful for reading something expensive (like runtime.MemStats) once for several metrics.
+func (r *Registry) OnScrape(fn func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks = append(r.hooks, fn)
+}
+
+// Counter adds a metric that only increases, like a number of updates.
+func (r *Registry) Counter(name, help string, fn func() float64) {
+	r.add(name, help, "counter", func(w *bufio.Writer, name string) {
+		sample(w, name, "", fn())
+	})
+}
+
+// Gauge adds a metric that can go up and down, like the number of Boids.
+func (r *Registry) Gauge(name, help string, fn func() float64) {
+	r.add(name, help, "gauge", func(w *bufio.Writer, name string) {
+		sample(w, name, "", fn())
+	})
+}
+
+// CounterVec adds a counter with one sample per value of the label, like one per worker.
+func (r *Registry) CounterVec(name, help, label string, fn func() map[string]float64) {
+	r.add(name, help, "counter", labelled(label, fn))
+}
+
+// GaugeVec adds a gauge with one sample per value of the label.
+func (r *Registry) GaugeVec(name, help, label string, fn func() map[string]float64) {
+	r.add(name, help, "gauge", labelled(label, fn))
+}
+
+// Histogram adds a metric with the cumulative counts of observations, up to and including each bound.
+// The fn returns the bounds (in increasing order), the counts for each bound, the total number
+// of observations and the sum of them.
+func (r *Registry) Histogram(name, help string, fn func() (bounds []float64, counts []uint64, count uint64, sum float64)) {
+	r.add(name, help, "histogram", func(w *bufio.Writer, name string) {
+		bounds, counts, count, sum := fn()
+		for i, b := range bounds {
+			sample(w, name+"_bucket", `le="`+format(b)+`"`, float64(counts[i]))
+		}
+		sample(w, name+"_bucket", `le="+Inf"`, float64(count))
+		sample(w, name+"_sum", "", sum)
+		sample(w, name+"_count", "", float64(count))
+	})
+}
+
+func labelled(label string, fn func() map[string]float64) func(w *bufio.Writer, name string) {
+	return func(w *bufio.Writer, name string) {
+		values := fn()
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		// Sorted as numbers when possible, so worker 10 comes after worker 9
+		sort.Slice(keys, func(i, j int) bool {
+			a, erra := strconv.Atoi(keys[i])
+			b, errb := strconv.Atoi(keys[j])
+			if erra == nil && errb == nil {
+				return a < b
+			}
+			return keys[i] < keys[j]
+		})
+		for _, k := range keys {
+			sample(w, name, label+`="`+escape(k)+`"`, values[k])
+		}
+	}
+}
+
+// WriteTo writes all metrics to w, in the Prometheus text format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, fn := range r.hooks {
+		fn()
+	}
+	cw := &countWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, m := range r.metrics {
+		bw.WriteString("# HELP " + m.name + " " + strings.ReplaceAll(m.help, "\n", `\n`) + "\n")
+		bw.WriteString("# TYPE " + m.name + " " + m.kind + "\n")
+		m.write(bw, m.name)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// ServeHTTP implements http.Handler.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", ContentType)
+	r.WriteTo(w)
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func sample(w *bufio.Writer, name, labels string, v float64) {
+	w.WriteString(name)
+	if labels != "" {
+		w.WriteString("{" + labels + "}")
+	}
+	w.WriteString(" " + format(v) + "\n")
+}
+
+func format(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,76 @@
+package monitor
+
+import (
+	"bytes"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	scrapes := 0
+	r.OnScrape(func() { scrapes++ })
+	r.Counter("test_total", "A counter.", func() float64 { return 3 })
+	r.Gauge("test_gauge", "A gauge\nwith two lines.", func() float64 { return math.Inf(-1) })
+	r.GaugeVec("test_vec", "A labelled gauge.", "worker", func() map[string]float64 {
+		return map[string]float64{"10": 1, "9": 0.5, `a"b`: 2}
+	})
+	r.Histogram("test_seconds", "A histogram.", func() ([]float64, []uint64, uint64, float64) {
+		return []float64{0.001, 0.1}, []uint64{1, 4}, 5, 1.25
+	})
+
+	expected := `# HELP test_total A counter.
+# TYPE test_total counter
+test_total 3
+# HELP test_gauge A gauge\nwith two lines.
+# TYPE test_gauge gauge
+test_gauge -Inf
+# HELP test_vec A labelled gauge.
+# TYPE test_vec gauge
+test_vec{worker="9"} 0.5
+test_vec{worker="10"} 1
+test_vec{worker="a\"b"} 2
+# HELP test_seconds A histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{le="0.001"} 1
+test_seconds_bucket{le="0.1"} 4
+test_seconds_bucket{le="+Inf"} 5
+test_seconds_sum 1.25
+test_seconds_count 5
+`
+	var buf bytes.Buffer
+	n, err := r.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, expected)
+	}
+	if n != int64(buf.Len()) || scrapes != 1 {
+		t.Errorf("got %d bytes and %d scrapes, expected %d and 1", n, scrapes, buf.Len())
+	}
+
+	t.Run("duplicate", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("got no panic, expected one for a duplicate name")
+			}
+		}()
+		r.Gauge("test_total", "", func() float64 { return 0 })
+	})
+
+	t.Run("http", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		if ct := rec.Header().Get("Content-Type"); rec.Code != http.StatusOK || ct != ContentType {
+			t.Errorf("got status %d and content type %q, expected 200 and %q", rec.Code, ct, ContentType)
+		}
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("POST", "/metrics", nil))
+		if rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("got status %d, expected 405", rec.Code)
+		}
+	})
+}
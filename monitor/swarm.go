@@ -0,0 +1,165 @@
+package monitor
+
+import (
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lmas/akvarium/boids"
+	"github.com/lmas/akvarium/boids/metrics"
+)
+
+// Rates (like updates per second) are averaged over this window.
+const rateWindow time.Duration = time.Second
+
+// Swarm collects metrics for a Swarm, adding them to a Registry.
+// Counters and timings are read from Swarm.Stats while scraping, but anything that reads the
+// Boids (like the flock metrics) has to be collected by the simulation's goroutine, see Update.
+type Swarm struct {
+	FlockEvery uint64 // Computes the flock metrics every n:th Update, as they're expensive.
+
+	swarm *boids.Swarm
+
+	// Only used by the simulation's goroutine.
+	since uint64 // Updates since the flock metrics were computed.
+	start time.Time
+	prev  boids.Stats
+
+	mu         sync.Mutex // Protects the values collected by Update.
+	boids      int
+	tps        float64
+	neighbours float64
+	flock      metrics.Metrics
+}
+
+// NewSwarm returns a collector for the Swarm, registering all of it's metrics in the Registry.
+// It also registers the Go runtime's allocation and GC metrics.
+func NewSwarm(r *Registry, s *boids.Swarm) *Swarm {
+	m := &Swarm{
+		FlockEvery: 30,
+		swarm:      s,
+		start:      time.Now(),
+		prev:       s.Stats(),
+		boids:      len(s.Boids),
+	}
+	m.register(r)
+	registerRuntime(r)
+	return m
+}
+
+// Update collects the metrics that reads the Boids. It must be called after each Swarm.Update,
+// from the same goroutine and with the same dirty flag.
+func (m *Swarm) Update(dirty bool) {
+	m.since++
+	if m.since >= m.FlockEvery && dirty {
+		// Only right after a dirty update, as it rebuilds the Index (used for the nearest
+		// neighbours) before the Boids moves on the next update
+		m.since = 0
+		f := metrics.Compute(m.swarm)
+		m.mu.Lock()
+		m.flock = f
+		m.mu.Unlock()
+	}
+
+	elapsed := time.Since(m.start)
+	if elapsed < rateWindow {
+		return
+	}
+	st := m.swarm.Stats()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.boids = len(m.swarm.Boids)
+	m.tps = float64(st.Updates-m.prev.Updates) / elapsed.Seconds()
+	if dirty := st.Dirty - m.prev.Dirty; dirty > 0 && m.boids > 0 {
+		m.neighbours = float64(st.Neighbours-m.prev.Neighbours) / float64(dirty) / float64(m.boids)
+	}
+	m.start, m.prev = time.Now(), st
+}
+
+// get reads one of the values collected by Update.
+func (m *Swarm) get(fn func() float64) func() float64 {
+	return func() float64 {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return fn()
+	}
+}
+
+func (m *Swarm) register(r *Registry) {
+	stats := func(fn func(st boids.Stats) float64) func() float64 {
+		return func() float64 {
+			return fn(m.swarm.Stats())
+		}
+	}
+	r.Counter("akvarium_updates_total", "Number of swarm updates.",
+		stats(func(st boids.Stats) float64 { return float64(st.Updates) }))
+	r.Counter("akvarium_dirty_updates_total", "Number of swarm updates that updated the velocities and the index.",
+		stats(func(st boids.Stats) float64 { return float64(st.Dirty) }))
+	r.Histogram("akvarium_update_duration_seconds", "Time spent in each swarm update.",
+		func() ([]float64, []uint64, uint64, float64) {
+			st := m.swarm.Stats()
+			bounds := make([]float64, len(boids.StatsBuckets))
+			for i, b := range boids.StatsBuckets {
+				bounds[i] = b.Seconds()
+			}
+			counts := st.Buckets
+			if counts == nil {
+				counts = make([]uint64, len(bounds))
+			}
+			return bounds, counts, st.Updates, st.Duration.Seconds()
+		})
+	r.Counter("akvarium_index_seconds_total", "Time spent building the spatial index.",
+		stats(func(st boids.Stats) float64 { return st.Index.Seconds() }))
+	r.Counter("akvarium_compute_seconds_total", "Time spent waiting for the workers to update the boids.",
+		stats(func(st boids.Stats) float64 { return st.Compute.Seconds() }))
+	r.CounterVec("akvarium_worker_busy_seconds_total", "Time each worker spent updating boids.", "worker",
+		func() map[string]float64 {
+			st := m.swarm.Stats()
+			busy := make(map[string]float64, len(st.Workers))
+			for i, d := range st.Workers {
+				busy[strconv.Itoa(i)] = d.Seconds()
+			}
+			return busy
+		})
+	r.Counter("akvarium_neighbours_visited_total", "Number of neighbours visited by all boids.",
+		stats(func(st boids.Stats) float64 { return float64(st.Neighbours) }))
+
+	r.Gauge("akvarium_boids", "Number of boids in the swarm.",
+		m.get(func() float64 { return float64(m.boids) }))
+	r.Gauge("akvarium_neighbours_per_boid", "Mean number of neighbours visited per boid and velocity update.",
+		m.get(func() float64 { return m.neighbours }))
+	r.Gauge("akvarium_tps", "Swarm updates per second.",
+		m.get(func() float64 { return m.tps }))
+
+	r.Gauge("akvarium_flock_polarization", "Flock alignment, from 0 for random directions to 1 when all boids move the same way.",
+		m.get(func() float64 { return m.flock.Polarization }))
+	r.Gauge("akvarium_flock_milling", "Flock rotation around it's center, from 0 for none to 1 when all boids circle around it.",
+		m.get(func() float64 { return m.flock.Milling }))
+	r.Gauge("akvarium_flock_nearest_neighbour_pixels", "Mean distance to the closest neighbour.",
+		m.get(func() float64 { return m.flock.NearestNeighbour }))
+	r.Gauge("akvarium_flock_radius_pixels", "Mean distance to the flock's center of mass.",
+		m.get(func() float64 { return m.flock.Radius }))
+	r.Gauge("akvarium_flock_density", "Number of boids per square pixel, within the flock radius.",
+		m.get(func() float64 { return m.flock.Density }))
+	r.Gauge("akvarium_flock_speed_mean", "Mean speed of the boids.",
+		m.get(func() float64 { return m.flock.Speed.Mean }))
+}
+
+// registerRuntime adds the Go runtime's allocation and GC metrics, reading the MemStats once per scrape.
+func registerRuntime(r *Registry) {
+	var ms runtime.MemStats
+	r.OnScrape(func() { runtime.ReadMemStats(&ms) })
+	r.Counter("go_memstats_mallocs_total", "Number of heap objects allocated.",
+		func() float64 { return float64(ms.Mallocs) })
+	r.Counter("go_memstats_alloc_bytes_total", "Number of bytes allocated on the heap.",
+		func() float64 { return float64(ms.TotalAlloc) })
+	r.Gauge("go_memstats_heap_alloc_bytes", "Number of bytes allocated on the heap and still in use.",
+		func() float64 { return float64(ms.HeapAlloc) })
+	r.Counter("go_gc_cycles_total", "Number of completed GC cycles.",
+		func() float64 { return float64(ms.NumGC) })
+	r.Counter("go_gc_pause_seconds_total", "Time spent in GC stop-the-world pauses.",
+		func() float64 { return float64(ms.PauseTotalNs) / 1e9 })
+	r.Gauge("go_goroutines", "Number of goroutines.",
+		func() float64 { return float64(runtime.NumGoroutine()) })
+}
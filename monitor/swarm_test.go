@@ -0,0 +1,96 @@
+package monitor
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/lmas/akvarium/boids"
+	"github.com/lmas/akvarium/boids/metrics"
+)
+
+// parse returns the value of each sample, keyed by the name and labels.
+func parse(t *testing.T, r *Registry) map[string]float64 {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := r.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	samples := make(map[string]float64)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		v, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			t.Fatalf("got bad sample %q: %s", line, err)
+		}
+		samples[line[:i]] = v
+	}
+	return samples
+}
+
+func TestSwarm(t *testing.T) {
+	conf := boids.DefaultConf()
+	conf.Boids = 100
+	conf.Workers = 2
+	conf.Spawn = [2]boids.Vector{boids.NewVector(0, 0), boids.NewVector(200, 200)}
+	s, err := boids.New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	r := NewRegistry()
+	m := NewSwarm(r, s)
+	m.FlockEvery = 4
+
+	target := boids.NewVector(100, 100)
+	// Starts with a position update, so the tick's parity doesn't match the dirty updates
+	for i := 1; i <= 20; i++ {
+		s.Update(i%2 == 0, target)
+		m.Update(i%2 == 0)
+	}
+	flock := metrics.Compute(s)
+	m.start = m.start.Add(-rateWindow) // Pretends a whole window has passed
+	s.Update(false, target)
+	m.Update(false)
+
+	got := parse(t, r)
+	for name, expected := range map[string]float64{
+		"akvarium_updates_total":                             21,
+		"akvarium_dirty_updates_total":                       10,
+		"akvarium_boids":                                     100,
+		`akvarium_update_duration_seconds_bucket{le="+Inf"}`: 21,
+		"akvarium_update_duration_seconds_count":             21,
+	} {
+		if got[name] != expected {
+			t.Errorf("got %s %v, expected %v", name, got[name], expected)
+		}
+	}
+	for _, name := range []string{
+		"akvarium_index_seconds_total",
+		"akvarium_compute_seconds_total",
+		`akvarium_worker_busy_seconds_total{worker="0"}`,
+		`akvarium_worker_busy_seconds_total{worker="1"}`,
+		"akvarium_neighbours_per_boid",
+		"akvarium_tps",
+		"akvarium_flock_polarization",
+		"akvarium_flock_nearest_neighbour_pixels",
+		"go_memstats_mallocs_total",
+	} {
+		if got[name] <= 0 {
+			t.Errorf("got %s %v, expected > 0", name, got[name])
+		}
+	}
+	if nn := got["akvarium_flock_nearest_neighbour_pixels"]; nn != flock.NearestNeighbour {
+		t.Errorf("got nearest neighbour %v, expected %v from the last dirty update", nn, flock.NearestNeighbour)
+	}
+	if max := float64(conf.Boids); got["akvarium_neighbours_per_boid"] > max {
+		t.Errorf("got %v neighbours per boid, expected at most %v", got["akvarium_neighbours_per_boid"], max)
+	}
+	if _, ok := got[`akvarium_worker_busy_seconds_total{worker="2"}`]; ok {
+		t.Errorf("got 3 workers, expected 2")
+	}
+}
@@ -0,0 +1,250 @@
+package render
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"io"
+)
+
+// APNG encodes frames into an animated PNG, without any loss of colours (unlike GIF).
+// As the number of frames must be written before the first frame, it has to be known up front.
+// Like GIF, only the area that changed since the previous frame is encoded.
+type APNG struct {
+	w      *bufio.Writer
+	frames int
+	fps    int
+	seq    uint32
+	added  int
+	prev   *image.RGBA
+	buf    bytes.Buffer
+	err    error
+}
+
+// NewAPNG returns an APNG encoder for the number of frames, showing fps frames per second
+// (at least 1), that loops forever.
+func NewAPNG(w io.Writer, frames, fps int) *APNG {
+	return &APNG{
+		w:      bufio.NewWriter(w),
+		frames: frames,
+		fps:    fps,
+	}
+}
+
+// AddFrame compresses and encodes the next frame.
+func (a *APNG) AddFrame(img *image.RGBA) error {
+	if a.err != nil {
+		return a.err
+	}
+	if a.added >= a.frames {
+		return fmt.Errorf("too many frames, expected %d", a.frames)
+	}
+	area := img.Rect
+	if a.prev == nil {
+		a.header(img.Rect.Dx(), img.Rect.Dy())
+		a.prev = image.NewRGBA(img.Rect)
+	} else {
+		if img.Rect.Size() != a.prev.Rect.Size() {
+			return errors.New("all frames must have the same size")
+		}
+		area = changedRGBA(a.prev, img)
+		if area.Empty() {
+			// Nothing changed, but a frame is still needed to keep the timing
+			area = image.Rect(img.Rect.Min.X, img.Rect.Min.Y, img.Rect.Min.X+1, img.Rect.Min.Y+1)
+		}
+	}
+	copy(a.prev.Pix, img.Pix)
+
+	// Frame control, replacing the area with the new pixels
+	fc := make([]byte, 26)
+	binary.BigEndian.PutUint32(fc[0:], a.seq)
+	binary.BigEndian.PutUint32(fc[4:], uint32(area.Dx()))
+	binary.BigEndian.PutUint32(fc[8:], uint32(area.Dy()))
+	binary.BigEndian.PutUint32(fc[12:], uint32(area.Min.X-img.Rect.Min.X))
+	binary.BigEndian.PutUint32(fc[16:], uint32(area.Min.Y-img.Rect.Min.Y))
+	binary.BigEndian.PutUint16(fc[20:], 1)
+	binary.BigEndian.PutUint16(fc[22:], uint16(a.fps))
+	a.seq++
+	a.chunk("fcTL", fc)
+
+	a.buf.Reset()
+	if a.added > 0 {
+		// Frame data is prefixed with the sequence number
+		var seq [4]byte
+		binary.BigEndian.PutUint32(seq[:], a.seq)
+		a.buf.Write(seq[:])
+		a.seq++
+	}
+	if err := compress(&a.buf, img.SubImage(area).(*image.RGBA)); err != nil {
+		a.err = err
+		return err
+	}
+	if a.added == 0 {
+		a.chunk("IDAT", a.buf.Bytes())
+	} else {
+		a.chunk("fdAT", a.buf.Bytes())
+	}
+	a.added++
+	return a.err
+}
+
+// Close writes the end of the PNG.
+func (a *APNG) Close() error {
+	if a.err != nil {
+		return a.err
+	}
+	if a.added != a.frames {
+		return fmt.Errorf("got %d frames, expected %d", a.added, a.frames)
+	}
+	a.chunk("IEND", nil)
+	if a.err != nil {
+		return a.err
+	}
+	return a.w.Flush()
+}
+
+func (a *APNG) header(w, h int) {
+	if a.err == nil {
+		_, a.err = a.w.WriteString("\x89PNG\r\n\x1a\n")
+	}
+	ih := make([]byte, 13)
+	binary.BigEndian.PutUint32(ih[0:], uint32(w))
+	binary.BigEndian.PutUint32(ih[4:], uint32(h))
+	ih[8] = 8 // Bit depth
+	ih[9] = 6 // Colour type RGBA
+	a.chunk("IHDR", ih)
+	// Animation control, with the number of frames and looping forever
+	ac := make([]byte, 8)
+	binary.BigEndian.PutUint32(ac[0:], uint32(a.frames))
+	a.chunk("acTL", ac)
+}
+
+func (a *APNG) chunk(name string, data []byte) {
+	if a.err != nil {
+		return
+	}
+	var head [8]byte
+	binary.BigEndian.PutUint32(head[:4], uint32(len(data)))
+	copy(head[4:], name)
+	crc := crc32.NewIEEE()
+	crc.Write(head[4:])
+	crc.Write(data)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	for _, b := range [][]byte{head[:], data, sum[:]} {
+		if _, err := a.w.Write(b); err != nil {
+			a.err = err
+			return
+		}
+	}
+}
+
+// changedRGBA returns the bounding box of pixels that differs between the images.
+func changedRGBA(prev, img *image.RGBA) image.Rectangle {
+	area := image.Rectangle{}
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	for y := 0; y < h; y++ {
+		p := prev.Pix[y*prev.Stride : y*prev.Stride+w*4]
+		c := img.Pix[img.PixOffset(img.Rect.Min.X, img.Rect.Min.Y+y):][:w*4]
+		if bytes.Equal(p, c) {
+			continue
+		}
+		minX, maxX := 0, w-1
+		for minX < w && bytes.Equal(p[minX*4:minX*4+4], c[minX*4:minX*4+4]) {
+			minX++
+		}
+		for maxX > minX && bytes.Equal(p[maxX*4:maxX*4+4], c[maxX*4:maxX*4+4]) {
+			maxX--
+		}
+		x := img.Rect.Min.X
+		area = area.Union(image.Rect(x+minX, img.Rect.Min.Y+y, x+maxX+1, img.Rect.Min.Y+y+1))
+	}
+	return area
+}
+
+// compress writes the filtered and zlib compressed scanlines of the image.
+// Each row uses the filter with the smallest sum of absolute differences, like image/png.
+func compress(w io.Writer, img *image.RGBA) error {
+	zw, err := zlib.NewWriterLevel(w, zlib.DefaultCompression)
+	if err != nil {
+		return err
+	}
+	n := img.Rect.Dx() * 4
+	prior := make([]byte, n)
+	var filtered [5][]byte
+	for i := range filtered {
+		filtered[i] = make([]byte, 1+n)
+		filtered[i][0] = byte(i)
+	}
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(img.Rect.Min.X, y):][:n]
+		best, bestSum := 0, -1
+		for f := range filtered {
+			sum := filter(filtered[f][1:], row, prior, f)
+			if bestSum < 0 || sum < bestSum {
+				best, bestSum = f, sum
+			}
+		}
+		if _, err := zw.Write(filtered[best]); err != nil {
+			return err
+		}
+		copy(prior, row)
+	}
+	return zw.Close()
+}
+
+// filter applies one of the PNG filters (none, sub, up, average or paeth) to the row,
+// returning the sum of absolute differences.
+func filter(dst, row, prior []byte, f int) int {
+	const bpp = 4
+	sum := 0
+	for i := range row {
+		var a, b, c int
+		if i >= bpp {
+			a, c = int(row[i-bpp]), int(prior[i-bpp])
+		}
+		b = int(prior[i])
+		var p int
+		switch f {
+		case 1:
+			p = a
+		case 2:
+			p = b
+		case 3:
+			p = (a + b) / 2
+		case 4:
+			p = paeth(a, b, c)
+		}
+		d := row[i] - byte(p)
+		dst[i] = d
+		if d < 128 {
+			sum += int(d)
+		} else {
+			sum += 256 - int(d)
+		}
+	}
+	return sum
+}
+
+func paeth(a, b, c int) int {
+	p := a + b - c
+	pa, pb, pc := abs(p-a), abs(p-b), abs(p-c)
+	if pa <= pb && pa <= pc {
+		return a
+	} else if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
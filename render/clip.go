@@ -0,0 +1,110 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/lmas/akvarium/boids"
+)
+
+// NewEncoder returns a GIF or APNG encoder, depending on the file extension (".gif", ".png"
+// or ".apng"). The number of frames is only required for APNG, while fps must be at least 1.
+func NewEncoder(w io.Writer, ext string, frames, fps int) (Encoder, error) {
+	if fps < 1 {
+		return nil, fmt.Errorf("fps must be at least 1, got %d", fps)
+	}
+	switch strings.ToLower(ext) {
+	case ".gif":
+		return NewGIF(w, fps), nil
+	case ".png", ".apng":
+		return NewAPNG(w, frames, fps), nil
+	}
+	return nil, fmt.Errorf("unknown clip format '%s', expected .gif, .png or .apng", ext)
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Pose is the part of a Boid needed for drawing it.
+type Pose struct {
+	Pos     boids.Vector
+	Heading float64
+	Size    float64
+}
+
+// Clip records the poses of the Boids in a Swarm, so the frames can be rendered and encoded
+// afterwards, without slowing down a running simulation.
+type Clip struct {
+	frames [][]Pose
+	times  []float64
+}
+
+// Add records the current poses of the Swarm's Boids, at time (in seconds).
+// It must not be called concurrently with Update.
+func (c *Clip) Add(s *boids.Swarm, time float64) {
+	poses := make([]Pose, len(s.Boids))
+	for i, b := range s.Boids {
+		poses[i] = Pose{b.Pos, b.Heading, b.Traits.Size}
+	}
+	c.frames = append(c.frames, poses)
+	c.times = append(c.times, time)
+}
+
+// Len returns the number of recorded frames.
+func (c *Clip) Len() int {
+	return len(c.frames)
+}
+
+// Encode renders each recorded frame and adds it to the encoder.
+// As rendering is slow, the frames are rendered in parallel in batches (one frame per CPU),
+// before being encoded in order.
+func (c *Clip) Encode(e Encoder, r *Renderer) error {
+	batch := make([]*image.RGBA, runtime.NumCPU())
+	for i := range batch {
+		batch[i] = image.NewRGBA(image.Rect(0, 0, r.Width, r.Height))
+	}
+	for start := 0; start < len(c.frames); start += len(batch) {
+		n := len(c.frames) - start
+		if n > len(batch) {
+			n = len(batch)
+		}
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				r.DrawPoses(batch[i], c.frames[start+i], c.times[start+i])
+			}(i)
+		}
+		wg.Wait()
+		for _, dst := range batch[:n] {
+			if err := e.AddFrame(dst); err != nil {
+				return err
+			}
+		}
+	}
+	return e.Close()
+}
+
+// Save renders and encodes the clip into a file, as GIF or APNG depending on it's extension.
+func (c *Clip) Save(path string, r *Renderer, fps int) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not create '%s': %s", path, err)
+	}
+	e, err := NewEncoder(f, filepath.Ext(path), c.Len(), fps)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if err := c.Encode(e, r); err != nil {
+		f.Close()
+		return fmt.Errorf("could not encode '%s': %s", path, err)
+	}
+	return f.Close()
+}
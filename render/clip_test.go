@@ -0,0 +1,182 @@
+package render
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/lmas/akvarium/boids"
+)
+
+// Frames with a striped gradient background and a square moving across it.
+// The palette is only quantized from the first frame, so the colours covered by the square
+// must also be visible elsewhere.
+func testFrames(n int) []*image.RGBA {
+	frames := make([]*image.RGBA, n)
+	for i := range frames {
+		img := image.NewRGBA(image.Rect(0, 0, 64, 32))
+		for y := 0; y < 32; y++ {
+			for x := 0; x < 64; x++ {
+				img.SetRGBA(x, y, color.RGBA{uint8(x / 16 * 60), uint8(y * 8), 0x80, 0xff})
+			}
+		}
+		draw.Draw(img, image.Rect(i*10, 8, i*10+8, 16), image.NewUniform(color.RGBA{0xff, 0xff, 0xff, 0xff}), image.Point{}, draw.Src)
+		frames[i] = img
+	}
+	return frames
+}
+
+func encode(t *testing.T, e Encoder, frames []*image.RGBA) {
+	t.Helper()
+	for _, f := range frames {
+		if err := e.AddFrame(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGIF(t *testing.T) {
+	frames := testFrames(4)
+	frames = append(frames, frames[3]) // No changes
+	var buf bytes.Buffer
+	encode(t, NewGIF(&buf, 30), frames)
+
+	g, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Image) != len(frames) {
+		t.Fatalf("got %d frames, expected %d", len(g.Image), len(frames))
+	}
+	if g.Delay[0] != 3 || g.LoopCount != 0 {
+		t.Errorf("got delay %d and loop count %d, expected 3 and 0", g.Delay[0], g.LoopCount)
+	}
+	if b := g.Image[1].Bounds(); b != image.Rect(0, 8, 18, 16) {
+		t.Errorf("got second frame bounds %s, expected only the changed area", b)
+	}
+
+	// Composites the frames and compares them with the originals
+	canvas := image.NewRGBA(frames[0].Rect)
+	for i, p := range g.Image {
+		draw.Draw(canvas, p.Bounds(), p, p.Bounds().Min, draw.Over)
+		if d := maxDiff(canvas, frames[i]); d > 16 {
+			t.Errorf("got max diff %d for frame %d, expected max 16", d, i)
+		}
+	}
+}
+
+func TestAPNG(t *testing.T) {
+	frames := testFrames(3)
+	var buf bytes.Buffer
+	encode(t, NewAPNG(&buf, len(frames), 30), frames)
+
+	// Decoders without APNG support shows the first frame
+	img, err := png.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := image.NewRGBA(img.Bounds())
+	draw.Draw(first, first.Rect, img, image.Point{}, draw.Src)
+	if d := maxDiff(first, frames[0]); d != 0 {
+		t.Errorf("got max diff %d for the first frame, expected 0", d)
+	}
+
+	var names []string
+	var seqs []uint32
+	b := buf.Bytes()[8:]
+	for len(b) > 0 {
+		n := binary.BigEndian.Uint32(b)
+		name := string(b[4:8])
+		names = append(names, name)
+		if name == "fcTL" || name == "fdAT" {
+			seqs = append(seqs, binary.BigEndian.Uint32(b[8:]))
+		}
+		b = b[12+n:]
+	}
+	expected := "IHDR acTL fcTL IDAT fcTL fdAT fcTL fdAT IEND"
+	if got := strings.Join(names, " "); got != expected {
+		t.Errorf("got chunks %q, expected %q", got, expected)
+	}
+	for i, s := range seqs {
+		if s != uint32(i) {
+			t.Errorf("got sequence %v, expected increasing from 0", seqs)
+			break
+		}
+	}
+
+	e := NewAPNG(&buf, 2, 30)
+	if err := e.AddFrame(frames[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Close(); err == nil {
+		t.Errorf("got no error for missing frames")
+	}
+}
+
+func TestClip(t *testing.T) {
+	conf := boids.DefaultConf()
+	conf.Boids = 20
+	conf.Spawn = [2]boids.Vector{boids.NewVector(0, 0), boids.NewVector(64, 32)}
+	s, err := boids.New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	clip := &Clip{}
+	for i := 0; i < 10; i++ {
+		s.Update(i%2 == 0, boids.NewVector(32, 16))
+		clip.Add(s, float64(i)/60)
+	}
+	var buf bytes.Buffer
+	r := New(64, 32, testSprite())
+	if err := clip.Encode(NewGIF(&buf, 30), r); err != nil {
+		t.Fatal(err)
+	}
+	g, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Image) != clip.Len() {
+		t.Errorf("got %d frames, expected %d", len(g.Image), clip.Len())
+	}
+}
+
+func TestNewEncoder(t *testing.T) {
+	for _, ext := range []string{".gif", ".PNG", ".apng"} {
+		if _, err := NewEncoder(nil, ext, 1, 30); err != nil {
+			t.Errorf("got error for %s: %s", ext, err)
+		}
+	}
+	if _, err := NewEncoder(nil, ".mp4", 1, 30); err == nil {
+		t.Errorf("got no error for .mp4")
+	}
+	for _, fps := range []int{0, -1} {
+		if _, err := NewEncoder(nil, ".gif", 1, fps); err == nil {
+			t.Errorf("got no error for %d fps", fps)
+		}
+	}
+}
+
+func maxDiff(a, b *image.RGBA) int {
+	max := 0
+	for i := range a.Pix {
+		d := int(a.Pix[i]) - int(b.Pix[i])
+		if d < 0 {
+			d = -d
+		}
+		if d > max {
+			max = d
+		}
+	}
+	return max
+}
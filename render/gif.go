@@ -0,0 +1,332 @@
+package render
+
+import (
+	"bufio"
+	"compress/lzw"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"sort"
+)
+
+// Encoder encodes frames into an animated image.
+type Encoder interface {
+	// AddFrame encodes the next frame. All frames must have the same size.
+	AddFrame(img *image.RGBA) error
+	// Close finishes the animation, but doesn't close the underlying writer.
+	Close() error
+}
+
+// Palette index used for pixels that didn't change since the previous frame.
+const gifTransparent uint8 = 255
+
+// GIF encodes frames into an animated GIF, streaming each frame to the writer as it's added.
+// The palette is quantized from the first frame (using median cut) and reused for all frames,
+// as the aquarium's colours stay mostly the same. Only the area that changed since the previous
+// frame is encoded, with unchanged pixels left transparent, which keeps the file size down.
+type GIF struct {
+	w       *bufio.Writer
+	delay   uint16 // In 1/100 seconds.
+	palette color.Palette
+	lookup  []int16 // Cache of the closest palette colour, for 15 bit colours.
+	prev    []uint8
+	indices []uint8
+	rect    image.Rectangle
+	frames  int
+	err     error
+}
+
+// NewGIF returns a GIF encoder showing fps frames per second (at least 1), that loops forever.
+func NewGIF(w io.Writer, fps int) *GIF {
+	delay := 100 / fps
+	if delay < 2 {
+		// Most viewers treats smaller delays as 10/100 seconds
+		delay = 2
+	}
+	return &GIF{
+		w:     bufio.NewWriter(w),
+		delay: uint16(delay),
+	}
+}
+
+func (g *GIF) write(v interface{}) {
+	if g.err == nil {
+		g.err = binary.Write(g.w, binary.LittleEndian, v)
+	}
+}
+
+// AddFrame quantizes and encodes the next frame.
+func (g *GIF) AddFrame(img *image.RGBA) error {
+	if g.err != nil {
+		return g.err
+	}
+	if g.palette == nil {
+		g.rect = img.Rect
+		g.palette = MedianCut(img, 255)
+		g.lookup = make([]int16, 1<<15)
+		for i := range g.lookup {
+			g.lookup[i] = -1
+		}
+		g.prev = make([]uint8, img.Rect.Dx()*img.Rect.Dy())
+		g.indices = make([]uint8, len(g.prev))
+		g.header()
+	} else if img.Rect.Size() != g.rect.Size() {
+		return errors.New("all frames must have the same size")
+	}
+
+	g.quantize(img)
+	first := g.frames == 0
+	area := g.rect.Sub(g.rect.Min)
+	if !first {
+		area = g.changed()
+	}
+	if area.Empty() {
+		// Nothing changed, but a frame is still needed to keep the timing
+		area = image.Rect(0, 0, 1, 1)
+	}
+	w := g.rect.Dx()
+	pix := make([]uint8, 0, area.Dx()*area.Dy())
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			i := y*w + x
+			if !first && g.indices[i] == g.prev[i] {
+				pix = append(pix, gifTransparent)
+			} else {
+				pix = append(pix, g.indices[i])
+			}
+		}
+	}
+	g.prev, g.indices = g.indices, g.prev
+
+	// Graphic control extension, with "do not dispose" and the transparent index
+	g.write([]byte{0x21, 0xf9, 0x04, 1<<2 | 1})
+	g.write(g.delay)
+	g.write([]byte{gifTransparent, 0x00})
+	// Image descriptor, without a local colour table
+	g.write(byte(0x2c))
+	g.write([4]uint16{uint16(area.Min.X), uint16(area.Min.Y), uint16(area.Dx()), uint16(area.Dy())})
+	g.write([]byte{0x00, 8})
+	if g.err != nil {
+		return g.err
+	}
+	bw := &blockWriter{w: g.w}
+	lw := lzw.NewWriter(bw, lzw.LSB, 8)
+	if _, err := lw.Write(pix); err != nil {
+		g.err = err
+		return err
+	}
+	if err := lw.Close(); err != nil {
+		g.err = err
+		return err
+	}
+	g.err = bw.close()
+	g.frames++
+	return g.err
+}
+
+// Close writes the GIF trailer.
+func (g *GIF) Close() error {
+	if g.palette == nil {
+		return errors.New("no frames added")
+	}
+	g.write(byte(0x3b))
+	if g.err != nil {
+		return g.err
+	}
+	return g.w.Flush()
+}
+
+func (g *GIF) header() {
+	g.write([]byte("GIF89a"))
+	g.write([2]uint16{uint16(g.rect.Dx()), uint16(g.rect.Dy())})
+	// Global colour table with 256 entries and 8 bits per channel
+	g.write([]byte{0xf7, 0x00, 0x00})
+	var table [256 * 3]byte
+	for i, c := range g.palette {
+		r, gr, b, _ := c.RGBA()
+		table[i*3], table[i*3+1], table[i*3+2] = byte(r>>8), byte(gr>>8), byte(b>>8)
+	}
+	g.write(table)
+	// Loops forever
+	g.write([]byte{0x21, 0xff, 0x0b})
+	g.write([]byte("NETSCAPE2.0"))
+	g.write([]byte{0x03, 0x01, 0x00, 0x00, 0x00})
+}
+
+// quantize maps each pixel to the closest palette colour.
+func (g *GIF) quantize(img *image.RGBA) {
+	w, h := g.rect.Dx(), g.rect.Dy()
+	for y := 0; y < h; y++ {
+		p := img.Pix[img.PixOffset(img.Rect.Min.X, img.Rect.Min.Y+y):]
+		for x := 0; x < w; x++ {
+			r, gr, b := p[x*4], p[x*4+1], p[x*4+2]
+			key := int(r>>3)<<10 | int(gr>>3)<<5 | int(b>>3)
+			idx := g.lookup[key]
+			if idx < 0 {
+				idx = int16(g.palette.Index(color.RGBA{r, gr, b, 0xff}))
+				g.lookup[key] = idx
+			}
+			g.indices[y*w+x] = uint8(idx)
+		}
+	}
+}
+
+// changed returns the bounding box of pixels that changed since the previous frame.
+func (g *GIF) changed() image.Rectangle {
+	w, h := g.rect.Dx(), g.rect.Dy()
+	minX, minY, maxX, maxY := w, h, -1, -1
+	for y := 0; y < h; y++ {
+		row := y * w
+		for x := 0; x < w; x++ {
+			if g.indices[row+x] == g.prev[row+x] {
+				continue
+			}
+			if x < minX {
+				minX = x
+			}
+			if x > maxX {
+				maxX = x
+			}
+			if y < minY {
+				minY = y
+			}
+			maxY = y
+		}
+	}
+	if maxX < 0 {
+		return image.Rectangle{}
+	}
+	return image.Rect(minX, minY, maxX+1, maxY+1)
+}
+
+// blockWriter splits the LZW data into sub-blocks of max 255 bytes.
+type blockWriter struct {
+	w   io.Writer
+	buf [256]byte
+	n   int
+}
+
+func (b *blockWriter) Write(p []byte) (int, error) {
+	total := len(p)
+	for len(p) > 0 {
+		n := copy(b.buf[1+b.n:], p)
+		b.n += n
+		p = p[n:]
+		if b.n == 255 {
+			if err := b.flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return total, nil
+}
+
+func (b *blockWriter) flush() error {
+	if b.n == 0 {
+		return nil
+	}
+	b.buf[0] = byte(b.n)
+	_, err := b.w.Write(b.buf[:1+b.n])
+	b.n = 0
+	return err
+}
+
+func (b *blockWriter) close() error {
+	if err := b.flush(); err != nil {
+		return err
+	}
+	_, err := b.w.Write([]byte{0x00})
+	return err
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Max number of pixels sampled when quantizing a palette.
+const maxSamples int = 1 << 16
+
+// MedianCut returns a palette with max colours, that best represents the colours in the image.
+// It repeatedly splits the group of colours with the largest range in half (at the median),
+// along the colour channel with the largest range, and then averages each group.
+func MedianCut(img *image.RGBA, max int) color.Palette {
+	b := img.Rect
+	step := b.Dx()*b.Dy()/maxSamples + 1
+	samples := make([][3]uint8, 0, b.Dx()*b.Dy()/step+1)
+	for i := 0; i < b.Dx()*b.Dy(); i += step {
+		p := img.Pix[img.PixOffset(b.Min.X+i%b.Dx(), b.Min.Y+i/b.Dx()):]
+		samples = append(samples, [3]uint8{p[0], p[1], p[2]})
+	}
+
+	boxes := []colourBox{newColourBox(samples)}
+	for len(boxes) < max {
+		// Splits the box with the largest range
+		best, bestRange := -1, 0
+		for i, box := range boxes {
+			if len(box.colours) > 1 && box.rng > bestRange {
+				best, bestRange = i, box.rng
+			}
+		}
+		if best < 0 {
+			break
+		}
+		a, c := boxes[best].split()
+		boxes[best] = a
+		boxes = append(boxes, c)
+	}
+
+	palette := make(color.Palette, len(boxes))
+	for i, box := range boxes {
+		palette[i] = box.average()
+	}
+	return palette
+}
+
+type colourBox struct {
+	colours [][3]uint8
+	channel int // Channel with the largest range.
+	rng     int
+}
+
+func newColourBox(colours [][3]uint8) colourBox {
+	box := colourBox{colours: colours}
+	for c := 0; c < 3; c++ {
+		min, max := 255, 0
+		for _, col := range colours {
+			v := int(col[c])
+			if v < min {
+				min = v
+			}
+			if v > max {
+				max = v
+			}
+		}
+		if max-min > box.rng || c == 0 {
+			box.channel, box.rng = c, max-min
+		}
+	}
+	return box
+}
+
+func (b colourBox) split() (colourBox, colourBox) {
+	c := b.channel
+	sort.Slice(b.colours, func(i, j int) bool {
+		return b.colours[i][c] < b.colours[j][c]
+	})
+	mid := len(b.colours) / 2
+	return newColourBox(b.colours[:mid]), newColourBox(b.colours[mid:])
+}
+
+func (b colourBox) average() color.RGBA {
+	var sum [3]int
+	for _, col := range b.colours {
+		for c := 0; c < 3; c++ {
+			sum[c] += int(col[c])
+		}
+	}
+	n := len(b.colours)
+	if n == 0 {
+		return color.RGBA{A: 0xff}
+	}
+	return color.RGBA{uint8(sum[0] / n), uint8(sum[1] / n), uint8(sum[2] / n), 0xff}
+}
@@ -0,0 +1,173 @@
+// Package render draws a Swarm into an image.RGBA using only the CPU, the same way as the
+// main simulation draws it with ebiten on the GPU. It can be used for headless tools and for
+// golden image tests, on machines without a display or GPU.
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/png" // Sprites are usually PNG images
+	"math"
+	"os"
+
+	"github.com/lmas/akvarium/boids"
+)
+
+// Background is the colour of the water.
+// https://www.color-name.com/light-ocean-blue.color
+var Background = color.RGBA{0x04, 0x78, 0x9B, 0xFF}
+
+// Renderer draws frames of a Swarm.
+type Renderer struct {
+	Width, Height int
+	Background    color.RGBA
+	Shader        bool // Applies the Underwater effect on top of the Boids.
+
+	sprite *image.RGBA
+}
+
+// New returns a Renderer for frames of the given size, drawing each Boid with the sprite.
+func New(width, height int, sprite image.Image) *Renderer {
+	b := sprite.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), sprite, b.Min, draw.Src)
+	return &Renderer{
+		Width:      width,
+		Height:     height,
+		Background: Background,
+		Shader:     true,
+		sprite:     rgba,
+	}
+}
+
+// LoadSprite loads an image from a file.
+func LoadSprite(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open '%s': %s", path, err)
+	}
+	defer f.Close()
+	i, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode '%s': %s", path, err)
+	}
+	return i, nil
+}
+
+// Frame returns a new image with the Swarm drawn into it, see Draw.
+func (r *Renderer) Frame(s *boids.Swarm, time float64) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, r.Width, r.Height))
+	r.Draw(dst, s, time)
+	return dst
+}
+
+// Draw fills dst with the background and draws all Boids on top of it, in order of their IDs.
+// The time (in seconds) is used for animating the Underwater effect.
+func (r *Renderer) Draw(dst *image.RGBA, s *boids.Swarm, time float64) {
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(r.Background), image.Point{}, draw.Src)
+	for _, b := range s.Boids {
+		r.DrawSprite(dst, b.Pos, b.Heading, b.Traits.Size)
+	}
+	if r.Shader {
+		Underwater(dst, time)
+	}
+}
+
+// DrawPoses works like Draw, but draws previously recorded poses instead of a Swarm.
+func (r *Renderer) DrawPoses(dst *image.RGBA, poses []Pose, time float64) {
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(r.Background), image.Point{}, draw.Src)
+	for _, p := range poses {
+		r.DrawSprite(dst, p.Pos, p.Heading, p.Size)
+	}
+	if r.Shader {
+		Underwater(dst, time)
+	}
+}
+
+// DrawSprite draws the sprite centered at pos, turned towards angle and scaled by size.
+// It's sampled bilinearly, like ebiten's FilterLinear.
+func (r *Renderer) DrawSprite(dst *image.RGBA, pos boids.Vector, angle, size float64) {
+	sw, sh := r.sprite.Rect.Dx(), r.sprite.Rect.Dy()
+	t := SpriteTransform(pos, angle, size, float64(sw), float64(sh))
+	inv, ok := t.Inverse()
+	if !ok {
+		return
+	}
+
+	// Only visit the pixels covered by the transformed sprite
+	min, max := boids.NewVector(math.Inf(1), math.Inf(1)), boids.NewVector(math.Inf(-1), math.Inf(-1))
+	for _, c := range []boids.Vector{{X: 0, Y: 0}, {X: float64(sw), Y: 0}, {X: 0, Y: float64(sh)}, {X: float64(sw), Y: float64(sh)}} {
+		p := t.Apply(c)
+		min = boids.NewVector(math.Min(min.X, p.X), math.Min(min.Y, p.Y))
+		max = boids.NewVector(math.Max(max.X, p.X), math.Max(max.Y, p.Y))
+	}
+	area := image.Rect(int(math.Floor(min.X)), int(math.Floor(min.Y)), int(math.Ceil(max.X)), int(math.Ceil(max.Y)))
+	area = area.Intersect(dst.Rect)
+
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			src := inv.Apply(boids.NewVector(float64(x)+0.5, float64(y)+0.5))
+			c := r.sample(src.X-0.5, src.Y-0.5)
+			if c[3] == 0 {
+				continue
+			}
+			i := dst.PixOffset(x, y)
+			p := dst.Pix[i : i+4 : i+4]
+			// Source over, with premultiplied alpha
+			a := 1 - c[3]/0xff
+			for j := 0; j < 4; j++ {
+				p[j] = uint8(math.Min(0xff, math.Round(c[j]+float64(p[j])*a)))
+			}
+		}
+	}
+}
+
+// sample returns the sprite's colour at x,y by interpolating between the four closest pixels,
+// with transparent pixels outside of the sprite.
+func (r *Renderer) sample(x, y float64) [4]float64 {
+	var c [4]float64
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := x-x0, y-y0
+	ix, iy := int(x0), int(y0)
+	weights := [4]float64{(1 - fx) * (1 - fy), fx * (1 - fy), (1 - fx) * fy, fx * fy}
+	for n, w := range weights {
+		px, py := ix+n%2, iy+n/2
+		if w == 0 || !(image.Point{px, py}).In(r.sprite.Rect) {
+			continue
+		}
+		i := r.sprite.PixOffset(px, py)
+		for j := 0; j < 4; j++ {
+			c[j] += float64(r.sprite.Pix[i+j]) * w
+		}
+	}
+	return c
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Headings within this angle of straight up or down rolls the sprite over, from upright
+// when pointing right to mirrored when pointing left.
+const rollAngle float64 = math.Pi / 12
+
+// Min vertical scale while rolling over, so sprites never shrink to a line when swimming straight up or down.
+const minRoll float64 = 0.25
+
+// SpriteRoll returns the vertical scale that keeps a sprite upright, as fish don't swim upside down.
+// It's 1 when pointing right and -1 when pointing left, changing smoothly near vertical so
+// the sprite doesn't flicker between mirrored and not when the heading jitters around it.
+func SpriteRoll(heading float64) float64 {
+	r := clamp(math.Cos(heading)/math.Sin(rollAngle), -1, 1)
+	if math.Abs(r) < minRoll {
+		return math.Copysign(minRoll, r)
+	}
+	return r
+}
+
+// SpriteTransform returns the transform for drawing a sprite of size w,h centered at pos,
+// rotated by the heading (see SpriteRoll) and scaled by size.
+func SpriteTransform(pos boids.Vector, heading, size, w, h float64) boids.Transform2D {
+	return boids.IdentityTransform().Translate(-w/2, -h/2).Scale(size, size*SpriteRoll(heading)).
+		Rotate(heading).Translate(pos.X, pos.Y)
+}
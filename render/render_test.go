@@ -0,0 +1,140 @@
+package render
+
+import (
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lmas/akvarium/boids"
+)
+
+var flagUpdate = flag.Bool("update", false, "Update the golden images")
+
+func TestSpriteRoll(t *testing.T) {
+	tests := []struct {
+		heading, expected float64
+	}{
+		{0, 1},
+		{math.Pi / 3, 1},
+		{-math.Pi / 3, 1},
+		{math.Pi, -1},
+		{-math.Pi * 2 / 3, -1},
+		{math.Pi/2 - rollAngle/2, math.Sin(rollAngle/2) / math.Sin(rollAngle)},
+		{math.Pi/2 + rollAngle/2, -math.Sin(rollAngle/2) / math.Sin(rollAngle)},
+		{math.Pi/2 - 0.01, minRoll},
+		{-math.Pi/2 - 0.01, -minRoll},
+	}
+	for _, tc := range tests {
+		if r := SpriteRoll(tc.heading); math.Abs(r-tc.expected) > 1e-9 {
+			t.Errorf("got %f for %f, expected %f", r, tc.heading, tc.expected)
+		}
+	}
+	// Rolls over gradually as the heading passes vertical, without jumping
+	prev := SpriteRoll(0)
+	for a := 0.0; a <= math.Pi; a += 0.001 {
+		r := SpriteRoll(a)
+		if d := math.Abs(r - prev); d > 2*minRoll+1e-9 || (d > 0.01 && math.Abs(r) > minRoll) {
+			t.Fatalf("got roll %f after %f at %f, expected a smooth change", r, prev, a)
+		}
+		prev = r
+	}
+}
+
+func TestSpriteTransform(t *testing.T) {
+	pos := boids.NewVector(100, 50)
+	for _, a := range []float64{0, 1, math.Pi, -2} {
+		tr := SpriteTransform(pos, a, 2, 26, 12)
+		if c := tr.Apply(boids.NewVector(13, 6)); !c.ApproxEqual(pos, 1e-9) {
+			t.Errorf("got center %s for angle %f, expected %s", c, a, pos)
+		}
+	}
+	// Sprites pointing left should have their head (right side) pointing left, with their back still up
+	tr := SpriteTransform(pos, math.Pi, 1, 26, 12)
+	if h := tr.Apply(boids.NewVector(26, 6)); !h.ApproxEqual(boids.NewVector(87, 50), 1e-9) {
+		t.Errorf("got head at %s, expected (87, 50)", h)
+	}
+	if b := tr.Apply(boids.NewVector(13, 0)); !b.ApproxEqual(boids.NewVector(100, 44), 1e-9) {
+		t.Errorf("got back at %s, expected (100, 44)", b)
+	}
+	// Rotated by the heading, without snapping to ±45°
+	tr = SpriteTransform(pos, 1.2, 1, 26, 12)
+	if h := tr.Apply(boids.NewVector(26, 6)); !h.ApproxEqual(pos.Addv(boids.NewVector(13*math.Cos(1.2), 13*math.Sin(1.2))), 1e-9) {
+		t.Errorf("got head at %s, expected it pointing along the heading", h)
+	}
+}
+
+// A tiny fish, red at the tail and white at the head, with transparent corners.
+func testSprite() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 8; x++ {
+			if (x == 0 || x == 7) && (y == 0 || y == 3) {
+				continue
+			}
+			c := color.RGBA{0xff, 0x40, 0x20, 0xff}
+			if x > 5 {
+				c = color.RGBA{0xff, 0xff, 0xff, 0xff}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestGolden(t *testing.T) {
+	s, err := boids.New(boids.Conf{
+		Boids:       4,
+		Workers:     1,
+		IndexOffset: 50,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for i, b := range s.Boids {
+		b.Pos = boids.NewVector(12+float64(i)*14, 12+float64(i%2)*16)
+		b.Heading = float64(i) * 1.1
+		b.Traits.Size = 1 + float64(i)*0.25
+	}
+
+	r := New(64, 40, testSprite())
+	img := r.Frame(s, 1.5)
+	path := filepath.Join("testdata", "golden.png")
+	if *flagUpdate {
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err := png.Encode(f, img); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	golden, err := LoadSprite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if golden.Bounds() != img.Bounds() {
+		t.Fatalf("got size %s, expected %s", img.Bounds(), golden.Bounds())
+	}
+	// Allows for tiny rounding differences between architectures
+	const tolerance = 2
+	for y := 0; y < img.Rect.Dy(); y++ {
+		for x := 0; x < img.Rect.Dx(); x++ {
+			g := color.RGBAModel.Convert(golden.At(x, y)).(color.RGBA)
+			c := img.RGBAAt(x, y)
+			for _, d := range []int{int(g.R) - int(c.R), int(g.G) - int(c.G), int(g.B) - int(c.B), int(g.A) - int(c.A)} {
+				if d < -tolerance || d > tolerance {
+					t.Fatalf("got colour %v at %d,%d, expected %v", c, x, y, g)
+				}
+			}
+		}
+	}
+}
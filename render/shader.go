@@ -0,0 +1,71 @@
+package render
+
+import (
+	"image"
+	"math"
+
+	"github.com/lmas/akvarium/boids"
+)
+
+// Underwater approximates the underwater shader (assets/shader.go) on the CPU, by drawing
+// animated sun rays from above the surface and darkening the water towards the depths.
+// The time is in seconds.
+func Underwater(dst *image.RGBA, time float64) {
+	b := dst.Rect
+	res := boids.NewVector(float64(b.Dx()), float64(b.Dy()))
+	rays := []sunRay{
+		// Lotsa smaller rays
+		{boids.NewVector(res.X*0.7, res.Y*-0.4), boids.NewVector(1.0, 0.2843).Normalize(), 15.1869, 29.5428, 1.1, 0.4},
+		// A few larger-ish rays, moving faster
+		{boids.NewVector(res.X*0.8, res.Y*-0.6), boids.NewVector(1.0, -0.0596).Normalize(), 21.4852, 17.9246, 1.5, 0.5},
+	}
+	for y := 0; y < b.Dy(); y++ {
+		coord := boids.NewVector(0, float64(y)+0.5)
+		depth := smoothstep(0, res.Y, coord.Y)
+		for x := 0; x < b.Dx(); x++ {
+			coord.X = float64(x) + 0.5
+			light := 0.0
+			for _, r := range rays {
+				light += r.strength(coord, res, time) * r.weight
+			}
+			// Emulate light attenuation towards the depths, for the sun rays
+			light *= (1 - depth) * 0.7
+			// And smooth darkness towards the depths for the whole screen
+			alpha := clamp(light+depth, 0, 1)
+			light = clamp(light, 0, 1)
+
+			i := dst.PixOffset(b.Min.X+x, b.Min.Y+y)
+			p := dst.Pix[i : i+4 : i+4]
+			for j := 0; j < 3; j++ {
+				p[j] = uint8(math.Min(0xff, math.Round(light*0xff+float64(p[j])*(1-alpha))))
+			}
+			p[3] = uint8(math.Min(0xff, math.Round(alpha*0xff+float64(p[3])*(1-alpha))))
+		}
+	}
+}
+
+// Source: https://www.shadertoy.com/view/MdXGW7
+type sunRay struct {
+	source, dir  boids.Vector
+	seedA, seedB float64
+	speed        float64
+	weight       float64
+}
+
+func (r sunRay) strength(coord, res boids.Vector, time float64) float64 {
+	diff := coord.Subv(r.source)
+	cos := diff.Normalize().Dot(r.dir)
+	val := (0.45 + 0.15*math.Sin(cos*r.seedA+time*r.speed)) + (0.3 + 0.2*math.Cos(-cos*r.seedB+time*r.speed))
+	strength := (res.X - diff.Length()) / res.X
+	return clamp(val, 0, 1) * clamp(strength, 0.5, 1)
+}
+
+func clamp(f, min, max float64) float64 {
+	return math.Max(min, math.Min(max, f))
+}
+
+// https://en.wikipedia.org/wiki/Smoothstep
+func smoothstep(e0, e1, x float64) float64 {
+	t := clamp((x-e0)/(e1-e0), 0, 1)
+	return t * t * (3 - 2*t)
+}
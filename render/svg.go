@@ -0,0 +1,268 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/lmas/akvarium/boids"
+)
+
+// Shape is how Boids are drawn in SVG diagrams.
+type Shape int
+
+const (
+	ShapeArrow    Shape = iota // An arrow pointing in the Boid's direction.
+	ShapeTriangle              // A narrow triangle pointing in the Boid's direction.
+)
+
+// Colours of the trails, from the slowest to the fastest speed.
+var trailColours = []color.RGBA{
+	{0x2C, 0x7B, 0xB6, 0xFF},
+	{0xAB, 0xD9, 0xE9, 0xFF},
+	{0xFF, 0xFF, 0xBF, 0xFF},
+	{0xFD, 0xAE, 0x61, 0xFF},
+	{0xD7, 0x19, 0x1C, 0xFF},
+}
+
+// Number of colours used for trails, as each trail is split into polylines of the same colour.
+const trailBuckets int = 8
+
+type trailPoint struct {
+	pos, vel boids.Vector
+	speed    float64
+}
+
+// SVG draws Boids and their trails as SVG diagrams, for docs and papers.
+// Boids are drawn at their latest positions, with their trails coloured by speed
+// (from blue for slow to red for fast).
+type SVG struct {
+	Min, Max   boids.Vector // Area shown in the diagram.
+	Shape      Shape
+	Size       float64     // Length of the Boids' shapes.
+	Trail      int         // Max number of positions kept in each trail, or 0 to keep all.
+	Background color.Color // Fills the diagram, or nil for transparent.
+	Targets    []boids.Vector
+	Routes     []*boids.Route // Also collects the routes followed by the Boids, see Add.
+
+	trails [][]trailPoint
+}
+
+// NewSVG returns a new SVG diagram, showing the area from min to max.
+func NewSVG(min, max boids.Vector) *SVG {
+	return &SVG{
+		Min:   min,
+		Max:   max,
+		Shape: ShapeArrow,
+		Size:  12,
+	}
+}
+
+// Add adds the current positions of the Swarm's Boids to their trails, and any new Routes
+// they're following to Routes.
+// It should be called after the positions have been updated (every second Update)
+// and it must not be called concurrently with Update.
+func (g *SVG) Add(s *boids.Swarm) {
+	g.grow(len(s.Boids))
+	for i, b := range s.Boids {
+		g.add(i, b.Pos, b.Vel)
+		if r := s.Route(i); r != nil {
+			g.addRoute(r)
+		}
+	}
+}
+
+// AddSnapshot adds the positions of the Boids in a Snapshot to their trails, and any new
+// routes to Routes.
+func (g *SVG) AddSnapshot(snap *boids.Snapshot) {
+	g.grow(len(snap.Boids))
+	for i, b := range snap.Boids {
+		g.add(i, b.Pos, b.Vel)
+	}
+	for _, rs := range snap.Routes {
+		g.addRoute(boids.NewRoute(rs.Mode, rs.Radius, rs.Waypoints...))
+	}
+}
+
+// addRoute adds r to Routes, unless it (or one with the same waypoints) is drawn already.
+func (g *SVG) addRoute(r *boids.Route) {
+	for _, o := range g.Routes {
+		if o == r || sameRoute(o, r) {
+			return
+		}
+	}
+	g.Routes = append(g.Routes, r)
+}
+
+func sameRoute(a, b *boids.Route) bool {
+	if a.Radius != b.Radius || len(a.Waypoints) != len(b.Waypoints) {
+		return false
+	}
+	for i := range a.Waypoints {
+		if a.Waypoints[i] != b.Waypoints[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *SVG) grow(n int) {
+	for len(g.trails) < n {
+		g.trails = append(g.trails, nil)
+	}
+}
+
+func (g *SVG) add(i int, pos, vel boids.Vector) {
+	t := append(g.trails[i], trailPoint{pos, vel, vel.Length()})
+	if g.Trail > 0 && len(t) > g.Trail {
+		t = t[len(t)-g.Trail:]
+	}
+	g.trails[i] = t
+}
+
+// Encode writes the diagram to w.
+// The last point of each trail is the Boid's latest position, where it's shape is drawn
+// pointing in the direction of it's velocity.
+func (g *SVG) Encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	size := g.Max.Subv(g.Min)
+	bw.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="no"?>` + "\n")
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" version="1.1" width="%s" height="%s" viewBox="%s %s %s %s">`+"\n",
+		num(size.X), num(size.Y), num(g.Min.X), num(g.Min.Y), num(size.X), num(size.Y))
+	if g.Background != nil {
+		fmt.Fprintf(bw, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"/>`+"\n",
+			num(g.Min.X), num(g.Min.Y), num(size.X), num(size.Y), hex(g.Background))
+	}
+
+	g.encodeTrails(bw)
+
+	if len(g.Routes) > 0 {
+		bw.WriteString(`<g id="routes" fill="none" stroke="#555555" stroke-width="1" stroke-dasharray="4 4">` + "\n")
+		for _, r := range g.Routes {
+			bw.WriteString(`<polyline points="`)
+			for i, p := range r.Waypoints {
+				if i > 0 {
+					bw.WriteByte(' ')
+				}
+				bw.WriteString(num(p.X) + "," + num(p.Y))
+			}
+			bw.WriteString(`"/>` + "\n")
+			for _, p := range r.Waypoints {
+				fmt.Fprintf(bw, `<circle cx="%s" cy="%s" r="%s"/>`+"\n", num(p.X), num(p.Y), num(r.Radius))
+			}
+		}
+		bw.WriteString("</g>\n")
+	}
+
+	if len(g.Targets) > 0 {
+		bw.WriteString(`<g id="targets" fill="none" stroke="#D7191C" stroke-width="2">` + "\n")
+		for _, t := range g.Targets {
+			r := g.Size / 2
+			fmt.Fprintf(bw, `<circle cx="%s" cy="%s" r="%s"/>`+"\n", num(t.X), num(t.Y), num(r))
+			fmt.Fprintf(bw, `<path d="M%s %sH%sM%s %sV%s"/>`+"\n",
+				num(t.X-r*2), num(t.Y), num(t.X+r*2), num(t.X), num(t.Y-r*2), num(t.Y+r*2))
+		}
+		bw.WriteString("</g>\n")
+	}
+
+	g.encodeBoids(bw)
+	bw.WriteString("</svg>\n")
+	return bw.Flush()
+}
+
+// encodeTrails splits each trail into polylines with the same colour, depending on the speed.
+func (g *SVG) encodeTrails(w *bufio.Writer) {
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, t := range g.trails {
+		for _, p := range t {
+			min, max = math.Min(min, p.speed), math.Max(max, p.speed)
+		}
+	}
+	bucket := func(speed float64) int {
+		if max <= min {
+			return 0
+		}
+		return int(math.Min(float64(trailBuckets-1), (speed-min)/(max-min)*float64(trailBuckets)))
+	}
+
+	w.WriteString(`<g id="trails" fill="none" stroke-width="1" stroke-linecap="round" stroke-linejoin="round">` + "\n")
+	for _, t := range g.trails {
+		for start := 0; start < len(t)-1; {
+			b := bucket(t[start+1].speed)
+			end := start + 1
+			for end+1 < len(t) && bucket(t[end+1].speed) == b {
+				end++
+			}
+			fmt.Fprintf(w, `<polyline stroke="%s" points="`, hex(gradient(float64(b)/float64(trailBuckets-1))))
+			for i, p := range t[start : end+1] {
+				if i > 0 {
+					w.WriteByte(' ')
+				}
+				w.WriteString(num(p.pos.X) + "," + num(p.pos.Y))
+			}
+			w.WriteString(`"/>` + "\n")
+			start = end
+		}
+	}
+	w.WriteString("</g>\n")
+}
+
+func (g *SVG) encodeBoids(w *bufio.Writer) {
+	if g.Shape == ShapeTriangle {
+		w.WriteString(`<g id="boids" fill="#FF8C1A" stroke="#000000" stroke-width="0.5">` + "\n")
+	} else {
+		w.WriteString(`<g id="boids" fill="none" stroke="#000000" stroke-width="1.5" stroke-linecap="round" stroke-linejoin="round">` + "\n")
+	}
+	for _, t := range g.trails {
+		if len(t) < 1 {
+			continue
+		}
+		p := t[len(t)-1].pos
+		dir := boids.NewVector(1, 0)
+		if v := t[len(t)-1].vel; v.Length() > 0 {
+			dir = v.Normalize()
+		}
+		head := p.Addv(dir.Mul(g.Size / 2))
+		tail := p.Subv(dir.Mul(g.Size / 2))
+		side := dir.Perp().Mul(g.Size / 4)
+		if g.Shape == ShapeTriangle {
+			left, right := tail.Addv(side), tail.Subv(side)
+			fmt.Fprintf(w, `<polygon points="%s,%s %s,%s %s,%s"/>`+"\n",
+				num(head.X), num(head.Y), num(left.X), num(left.Y), num(right.X), num(right.Y))
+			continue
+		}
+		back := p.Addv(dir.Mul(g.Size / 8))
+		left, right := back.Addv(side), back.Subv(side)
+		fmt.Fprintf(w, `<path d="M%s %sL%s %sM%s %sL%s %sL%s %s"/>`+"\n",
+			num(tail.X), num(tail.Y), num(head.X), num(head.Y),
+			num(left.X), num(left.Y), num(head.X), num(head.Y), num(right.X), num(right.Y))
+	}
+	w.WriteString("</g>\n")
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// num formats a coordinate with max 2 decimals, to keep the file size down.
+func num(f float64) string {
+	return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+}
+
+func hex(c color.Color) string {
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("#%02X%02X%02X", r>>8, g>>8, b>>8)
+}
+
+// gradient returns the trail colour at f, between 0 and 1.
+func gradient(f float64) color.RGBA {
+	f = clamp(f, 0, 1) * float64(len(trailColours)-1)
+	i := int(math.Min(math.Floor(f), float64(len(trailColours)-2)))
+	t := f - float64(i)
+	a, b := trailColours[i], trailColours[i+1]
+	mix := func(x, y uint8) uint8 {
+		return uint8(math.Round(float64(x) + (float64(y)-float64(x))*t))
+	}
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 0xFF}
+}
@@ -0,0 +1,140 @@
+package render
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/color"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/lmas/akvarium/boids"
+)
+
+// elements returns the number of each kind of element in the SVG, checking it's well formed.
+func elements(t *testing.T, b []byte) map[string]int {
+	t.Helper()
+	count := make(map[string]int)
+	d := xml.NewDecoder(bytes.NewReader(b))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return count
+		} else if err != nil {
+			t.Fatalf("got invalid SVG: %s", err)
+		}
+		if e, ok := tok.(xml.StartElement); ok {
+			count[e.Name.Local]++
+		}
+	}
+}
+
+func TestSVG(t *testing.T) {
+	conf := boids.DefaultConf()
+	conf.Boids = 10
+	conf.Spawn = [2]boids.Vector{boids.NewVector(0, 0), boids.NewVector(200, 100)}
+	s, err := boids.New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	target := boids.NewVector(100, 50)
+
+	t.Run("history", func(t *testing.T) {
+		g := NewSVG(conf.Spawn[0], conf.Spawn[1])
+		g.Trail = 20
+		g.Targets = []boids.Vector{target}
+		g.Routes = []*boids.Route{boids.NewRoute(boids.RouteLoop, 10, boids.NewVector(20, 20), boids.NewVector(180, 80))}
+		g.Background = color.White
+		for i := 0; i < 100; i++ {
+			s.Update(i%2 == 0, target)
+			if s.Tick()%2 == 0 {
+				g.Add(s)
+			}
+		}
+		var buf bytes.Buffer
+		if err := g.Encode(&buf); err != nil {
+			t.Fatal(err)
+		}
+		count := elements(t, buf.Bytes())
+		if count["path"] != conf.Boids+1 {
+			t.Errorf("got %d paths, expected one arrow per boid plus the target", count["path"])
+		}
+		if count["polyline"] < conf.Boids+1 {
+			t.Errorf("got %d polylines, expected at least one trail per boid plus the route", count["polyline"])
+		}
+		if count["circle"] != 3 || count["rect"] != 1 {
+			t.Errorf("got %d circles and %d rects, expected 3 and 1", count["circle"], count["rect"])
+		}
+		for _, tr := range g.trails {
+			if len(tr) != g.Trail {
+				t.Fatalf("got trail with %d points, expected %d", len(tr), g.Trail)
+			}
+		}
+	})
+
+	t.Run("snapshot", func(t *testing.T) {
+		g := NewSVG(conf.Spawn[0], conf.Spawn[1])
+		g.Shape = ShapeTriangle
+		g.AddSnapshot(s.Snapshot())
+		var buf bytes.Buffer
+		if err := g.Encode(&buf); err != nil {
+			t.Fatal(err)
+		}
+		count := elements(t, buf.Bytes())
+		if count["polygon"] != conf.Boids || count["polyline"] != 0 {
+			t.Errorf("got %d polygons and %d polylines, expected %d and 0", count["polygon"], count["polyline"], conf.Boids)
+		}
+		if !strings.Contains(buf.String(), `viewBox="0 0 200 100"`) {
+			t.Errorf("got %q, expected a viewBox covering the spawn area", buf.String())
+		}
+	})
+
+	t.Run("routes", func(t *testing.T) {
+		route := boids.NewRoute(boids.RouteLoop, 10, boids.NewVector(20, 20), boids.NewVector(180, 80))
+		s.SetRoute(route, 0, 1, 2)
+		s.SetRoute(boids.NewRoute(boids.RouteOnce, 5, boids.NewVector(100, 20)), 3)
+		g := NewSVG(conf.Spawn[0], conf.Spawn[1])
+		g.Add(s)
+		g.Add(s)
+		if len(g.Routes) != 2 || g.Routes[0] != route {
+			t.Errorf("got %d routes, expected the 2 followed by the boids", len(g.Routes))
+		}
+		g = NewSVG(conf.Spawn[0], conf.Spawn[1])
+		g.AddSnapshot(s.Snapshot())
+		g.AddSnapshot(s.Snapshot())
+		var buf bytes.Buffer
+		if err := g.Encode(&buf); err != nil {
+			t.Fatal(err)
+		}
+		count := elements(t, buf.Bytes())
+		if len(g.Routes) != 2 || count["circle"] != 3 {
+			t.Errorf("got %d routes with %d waypoints, expected 2 with 3", len(g.Routes), count["circle"])
+		}
+	})
+}
+
+func TestSVGTrailColours(t *testing.T) {
+	g := NewSVG(boids.NewVector(0, 0), boids.NewVector(100, 100))
+	// Speeding up along the trail, so it's split into a polyline for each speed
+	for i := 0; i < 4; i++ {
+		g.grow(1)
+		g.add(0, boids.NewVector(float64(i*10), 0), boids.NewVector(float64(i+1), 0))
+	}
+	var buf bytes.Buffer
+	if err := g.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if n := strings.Count(out, "<polyline"); n != 3 {
+		t.Errorf("got %d polylines, expected 3", n)
+	}
+	for _, c := range []string{hex(gradient(2.0 / 7)), hex(gradient(5.0 / 7)), hex(gradient(1))} {
+		if !strings.Contains(out, `stroke="`+c+`"`) {
+			t.Errorf("got no trail with colour %s", c)
+		}
+	}
+	if c := gradient(0); c != trailColours[0] {
+		t.Errorf("got %v for the slowest, expected %v", c, trailColours[0])
+	}
+}
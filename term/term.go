@@ -0,0 +1,227 @@
+// Package term draws a Swarm as text in a terminal, using ANSI escape codes. It needs no GPU,
+// so the aquarium can be run over SSH or in a tmux pane.
+//
+// Only the cells that changed since the previous frame are redrawn, to keep the output small.
+package term
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/lmas/akvarium/boids"
+	"github.com/lmas/akvarium/render"
+)
+
+// Mode decides how Boids are drawn.
+type Mode int
+
+const (
+	ModeGlyphs  Mode = iota // One ASCII glyph per Boid, pointing in it's direction.
+	ModeBraille             // One braille dot per Boid, with 2x4 dots per cell.
+)
+
+// Colours decides the colour depth used for shading.
+type Colours int
+
+const (
+	ColoursNone Colours = iota // No colours, for plain terminals.
+	Colours256                 // The 256 colour palette (xterm).
+	ColoursTrue                // 24 bit colours.
+)
+
+// ParseColours returns the colour depth with the name "none", "256" or "true".
+func ParseColours(name string) (Colours, error) {
+	switch name {
+	case "none":
+		return ColoursNone, nil
+	case "256":
+		return Colours256, nil
+	case "true":
+		return ColoursTrue, nil
+	}
+	return 0, fmt.Errorf("unknown colours '%s', expected none, 256 or true", name)
+}
+
+// Fish is the colour of the Boids, before shading.
+var Fish = color.RGBA{0xFF, 0x8C, 0x1A, 0xFF}
+
+// Glyphs for the 8 directions, starting at east and turning clockwise (as Y points down).
+var glyphs = [8]rune{'>', '\\', 'v', '/', '<', '\\', '^', '/'}
+
+type rgb [3]uint8
+
+type cell struct {
+	r      rune
+	fg, bg rgb
+}
+
+// Terminal draws frames of a Swarm onto a grid of cells.
+type Terminal struct {
+	Mode    Mode
+	Colours Colours
+
+	cols, rows int
+	min, max   boids.Vector // World coordinates covered by the grid.
+	cells      []cell
+	prev       []cell // Cells shown on the terminal, or nil if it must be redrawn fully.
+	buf        bytes.Buffer
+}
+
+// New returns a Terminal with a grid of cols x rows cells, covering the world from min to max.
+func New(cols, rows int, min, max boids.Vector) *Terminal {
+	return &Terminal{
+		Mode:    ModeGlyphs,
+		Colours: ColoursTrue,
+		cols:    cols,
+		rows:    rows,
+		min:     min,
+		max:     max,
+		cells:   make([]cell, cols*rows),
+	}
+}
+
+// Resize changes the size of the grid and redraws everything on the next Draw.
+func (t *Terminal) Resize(cols, rows int) {
+	t.cols, t.rows = cols, rows
+	t.cells = make([]cell, cols*rows)
+	t.prev = nil
+}
+
+// Render draws the Swarm into the grid, without writing anything to the terminal (see Draw).
+// It must not be called concurrently with Update.
+func (t *Terminal) Render(s *boids.Swarm) {
+	for y := 0; y < t.rows; y++ {
+		bg := shade(render.Background, t.depth(y))
+		for x := 0; x < t.cols; x++ {
+			t.cells[y*t.cols+x] = cell{' ', bg, bg}
+		}
+	}
+
+	// Sub-cell resolution
+	sx, sy := 1, 1
+	if t.Mode == ModeBraille {
+		sx, sy = 2, 4
+	}
+	size := t.max.Subv(t.min)
+	for _, b := range s.Boids {
+		p := b.Pos.Subv(t.min)
+		x := int(math.Floor(p.X / size.X * float64(t.cols*sx)))
+		y := int(math.Floor(p.Y / size.Y * float64(t.rows*sy)))
+		if x < 0 || y < 0 || x >= t.cols*sx || y >= t.rows*sy {
+			continue
+		}
+		c := &t.cells[(y/sy)*t.cols+x/sx]
+		c.fg = shade(Fish, t.depth(y/sy)*0.5)
+		if t.Mode == ModeBraille {
+			if c.r == ' ' {
+				c.r = brailleBlank
+			}
+			c.r |= braille(x%2, y%4)
+		} else {
+			c.r = Glyph(b.Vel.Angle())
+		}
+	}
+}
+
+// depth returns how deep a row is, from 0 at the surface to 1 at the bottom, like render.Underwater.
+func (t *Terminal) depth(row int) float64 {
+	d := (float64(row) + 0.5) / float64(t.rows)
+	return d * d * (3 - 2*d)
+}
+
+// Draw writes the cells that changed since the previous Draw to w.
+func (t *Terminal) Draw(w io.Writer) error {
+	t.buf.Reset()
+	full := t.prev == nil
+	if full {
+		t.prev = make([]cell, len(t.cells))
+		// Hides the cursor and clears the screen
+		t.buf.WriteString("\x1b[?25l\x1b[2J")
+	}
+
+	var last *cell // Colours set by the previous cell written.
+	next := -1     // Position of the cursor, after the previous cell written.
+	for i, c := range t.cells {
+		if !full && c == t.prev[i] {
+			continue
+		}
+		if i != next || i%t.cols == 0 {
+			t.buf.WriteString("\x1b[")
+			t.buf.WriteString(strconv.Itoa(i/t.cols + 1))
+			t.buf.WriteByte(';')
+			t.buf.WriteString(strconv.Itoa(i%t.cols + 1))
+			t.buf.WriteByte('H')
+		}
+		if t.Colours != ColoursNone && (last == nil || last.fg != c.fg || last.bg != c.bg) {
+			t.colour(c)
+		}
+		t.buf.WriteRune(c.r)
+		t.prev[i] = c
+		last, next = &t.prev[i], i+1
+	}
+	if t.buf.Len() == 0 {
+		return nil
+	}
+	if t.Colours != ColoursNone {
+		t.buf.WriteString("\x1b[0m")
+	}
+	_, err := w.Write(t.buf.Bytes())
+	return err
+}
+
+// Restore resets the colours and shows the cursor again, below the grid.
+func (t *Terminal) Restore(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "\x1b[0m\x1b[%d;1H\x1b[?25h\n", t.rows)
+	return err
+}
+
+func (t *Terminal) colour(c cell) {
+	if t.Colours == ColoursTrue {
+		fmt.Fprintf(&t.buf, "\x1b[38;2;%d;%d;%d;48;2;%d;%d;%dm", c.fg[0], c.fg[1], c.fg[2], c.bg[0], c.bg[1], c.bg[2])
+		return
+	}
+	fmt.Fprintf(&t.buf, "\x1b[38;5;%d;48;5;%dm", colour256(c.fg), colour256(c.bg))
+}
+
+////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Glyph returns the glyph pointing closest to the angle (in radians, with Y pointing down).
+func Glyph(angle float64) rune {
+	i := int(math.Round(angle/(math.Pi/4))) % 8
+	if i < 0 {
+		i += 8
+	}
+	return glyphs[i]
+}
+
+// Empty braille pattern, with the dots added as bits.
+const brailleBlank rune = 0x2800
+
+// Bits for the braille dots, as [x][y].
+var brailleDots = [2][4]rune{
+	{0x01, 0x02, 0x04, 0x40},
+	{0x08, 0x10, 0x20, 0x80},
+}
+
+func braille(x, y int) rune {
+	return brailleDots[x][y]
+}
+
+// colour256 returns the closest colour in the 6x6x6 colour cube of the 256 colour palette.
+func colour256(c rgb) int {
+	i := 16
+	for j, m := range []int{36, 6, 1} {
+		i += m * int(math.Round(float64(c[j])/0xff*5))
+	}
+	return i
+}
+
+// shade darkens the colour towards the depths.
+func shade(c color.RGBA, depth float64) rgb {
+	f := 1 - depth*0.8
+	return rgb{uint8(float64(c.R) * f), uint8(float64(c.G) * f), uint8(float64(c.B) * f)}
+}
@@ -0,0 +1,134 @@
+package term
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/lmas/akvarium/boids"
+)
+
+func testSwarm(t *testing.T, pos ...boids.Vector) *boids.Swarm {
+	t.Helper()
+	conf := boids.DefaultConf()
+	conf.Boids = len(pos)
+	conf.Spawn = [2]boids.Vector{boids.NewVector(0, 0), boids.NewVector(80, 40)}
+	s, err := boids.New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	for i, p := range pos {
+		s.Boids[i].Pos = p
+		s.Boids[i].Vel = boids.NewVector(1, 0)
+	}
+	return s
+}
+
+func TestGlyph(t *testing.T) {
+	tests := []struct {
+		angle    float64
+		expected rune
+	}{
+		{0, '>'},
+		{math.Pi / 2, 'v'},
+		{-math.Pi / 2, '^'},
+		{math.Pi, '<'},
+		{-math.Pi, '<'},
+		{math.Pi / 4, '\\'},
+		{-math.Pi / 4, '/'},
+		{math.Pi * 3 / 4, '/'},
+		{0.3, '>'},
+	}
+	for _, tc := range tests {
+		if g := Glyph(tc.angle); g != tc.expected {
+			t.Errorf("got %c for %f, expected %c", g, tc.angle, tc.expected)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	s := testSwarm(t, boids.NewVector(1, 1), boids.NewVector(3, 7), boids.NewVector(79, 39), boids.NewVector(-5, 10))
+	t.Run("glyphs", func(t *testing.T) {
+		tm := New(40, 10, boids.NewVector(0, 0), boids.NewVector(80, 40))
+		tm.Render(s)
+		if r := tm.cells[0].r; r != '>' {
+			t.Errorf("got %c at 0,0, expected >", r)
+		}
+		if r := tm.cells[1*40+1].r; r != '>' {
+			t.Errorf("got %c at 1,1, expected >", r)
+		}
+		if r := tm.cells[9*40+39].r; r != '>' {
+			t.Errorf("got %c at 39,9, expected >", r)
+		}
+	})
+	t.Run("braille", func(t *testing.T) {
+		// Each cell covers 4x8 world units, so the first two boids shares a cell
+		tm := New(20, 5, boids.NewVector(0, 0), boids.NewVector(80, 40))
+		tm.Mode = ModeBraille
+		tm.Render(s)
+		if r := tm.cells[0].r; r != brailleBlank|0x01|0x80 {
+			t.Errorf("got %c (%x) at 0,0, expected ⢁", r, r)
+		}
+		if r := tm.cells[1].r; r != ' ' {
+			t.Errorf("got %c at 1,0, expected an empty cell", r)
+		}
+	})
+}
+
+func TestDraw(t *testing.T) {
+	s := testSwarm(t, boids.NewVector(1, 1), boids.NewVector(41, 21))
+	tm := New(40, 10, boids.NewVector(0, 0), boids.NewVector(80, 40))
+	tm.Colours = ColoursNone
+
+	var buf bytes.Buffer
+	tm.Render(s)
+	if err := tm.Draw(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "\x1b[?25l\x1b[2J") || strings.Count(buf.String(), ">") != 2 {
+		t.Errorf("got %q, expected a cleared screen with 2 boids", buf.String())
+	}
+
+	buf.Reset()
+	tm.Render(s)
+	tm.Draw(&buf)
+	if buf.Len() != 0 {
+		t.Errorf("got %q, expected nothing when nothing changed", buf.String())
+	}
+
+	buf.Reset()
+	s.Boids[0].Pos = boids.NewVector(3, 1)
+	tm.Render(s)
+	tm.Draw(&buf)
+	if got, expected := buf.String(), "\x1b[1;1H >"; got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+
+	buf.Reset()
+	tm.Colours = Colours256
+	tm.Resize(40, 10)
+	tm.Render(s)
+	tm.Draw(&buf)
+	if !strings.Contains(buf.String(), "\x1b[38;5;") || !strings.HasSuffix(buf.String(), "\x1b[0m") {
+		t.Errorf("got %q, expected a full redraw with colours", buf.String())
+	}
+}
+
+func TestColour256(t *testing.T) {
+	tests := []struct {
+		c        rgb
+		expected int
+	}{
+		{rgb{0, 0, 0}, 16},
+		{rgb{0xff, 0xff, 0xff}, 231},
+		{rgb{0xff, 0, 0}, 196},
+		{rgb{0, 0x80, 0xff}, 16 + 3*6 + 5},
+	}
+	for _, tc := range tests {
+		if c := colour256(tc.c); c != tc.expected {
+			t.Errorf("got %d for %v, expected %d", c, tc.c, tc.expected)
+		}
+	}
+}
@@ -4,6 +4,7 @@ package main
 
 import (
 	"errors"
+	"flag"
 	"fmt"
 	"image"
 	"image/color"
@@ -24,22 +25,10 @@ var errQuit = errors.New("quit")
 var minVec = boids.NewVector(-1, -1)
 var maxVec = boids.NewVector(float64(screenWidth), float64(screenHeight))
 
-var conf = boids.Conf{
-	Spawn:               [2]boids.Vector{minVec, maxVec},
-	Seed:                0,
-	Boids:               500,
-	Workers:             10,
-	IndexOffset:         50,
-	CohesionFactor:      0.001,
-	AlignmentFactor:     0.05,
-	SeparationRange:     20,
-	SeparationFactor:    0.3,
-	TargetRange:         50,
-	TargetRepelFactor:   0.3,
-	TargetAttractFactor: 0.00004,
-	VelocityMax:         1,
-	VelocityMin:         0.5,
-}
+var (
+	flagConfig = flag.String("config", "", "Load the swarm conf from a JSON/TOML file (same format as the main simulation)")
+	flagPreset = flag.String("preset", "", "Use a named preset for the swarm (school, murmuration, insects or baitball), defaults to school")
+)
 
 type debugSim struct {
 	swarm  *boids.Swarm
@@ -50,8 +39,13 @@ type debugSim struct {
 }
 
 func main() {
+	flag.Parse()
+	swarm, err := utils.LoadSwarm(*flagPreset, *flagConfig, "")
+	if err != nil {
+		panic(err)
+	}
 	s := &debugSim{
-		swarm: boids.New(conf),
+		swarm: swarm,
 		op: &ebiten.DrawImageOptions{
 			Filter: ebiten.FilterLinear,
 		},
@@ -108,7 +102,7 @@ func (s *debugSim) Draw(screen *ebiten.Image) {
 	leader := s.swarm.Boids[0]
 	// Shows bins around leader
 	k := s.swarm.Index.Key(leader)
-	r := float64(conf.IndexOffset)
+	r := float64(s.swarm.Conf.IndexOffset)
 	for i := -1; i < 2; i++ {
 		for j := -1; j < 2; j++ {
 			x := float64(k[0]+i) * r
@@ -0,0 +1,76 @@
+package main
+
+// This tool runs a headless simulation and exports the boids' trajectories, for offline analysis.
+//
+//	go run tools/export.go -steps 2000 -every 10 -out trajectories.csv
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lmas/akvarium/boids"
+	"github.com/lmas/akvarium/utils"
+)
+
+var (
+	flagConfig = flag.String("config", "", "Load the swarm conf from a JSON/TOML file (same format as the main simulation)")
+	flagPreset = flag.String("preset", "", "Use a named preset for the swarm (school, murmuration, insects or baitball), defaults to school")
+	flagSteps  = flag.Int("steps", 1000, "Number of updates to run")
+	flagEvery  = flag.Uint64("every", 2, "Only export every n:th update")
+	flagRegion = flag.String("region", "", "Only export boids within a bounding box, as 'minX,minY,maxX,maxY'")
+	flagFormat = flag.String("format", "", "Export format (csv or jsonl), defaults to the file extension of -out")
+	flagOut    = flag.String("out", "-", "Output file, or - for stdout")
+)
+
+func main() {
+	flag.Parse()
+	swarm, err := utils.LoadSwarm(*flagPreset, *flagConfig, "")
+	if err != nil {
+		panic(err)
+	}
+
+	name := *flagFormat
+	if name == "" {
+		name = strings.TrimPrefix(filepath.Ext(*flagOut), ".")
+	}
+	if name == "" {
+		name = "csv"
+	}
+	format, err := boids.ParseExportFormat(name)
+	if err != nil {
+		panic(err)
+	}
+
+	out := os.Stdout
+	if *flagOut != "-" {
+		out, err = os.Create(*flagOut)
+		if err != nil {
+			panic(err)
+		}
+		defer out.Close()
+	}
+	e := boids.NewExporter(out, format)
+	e.Every = *flagEvery
+	if *flagRegion != "" {
+		var min, max boids.Vector
+		_, err := fmt.Sscanf(*flagRegion, "%f,%f,%f,%f", &min.X, &min.Y, &max.X, &max.Y)
+		if err != nil {
+			panic(fmt.Errorf("bad region '%s': %s", *flagRegion, err))
+		}
+		e.SetRegion(min, max)
+	}
+
+	target := swarm.Conf.Spawn[0].Addv(swarm.Conf.Spawn[1]).Div(2)
+	for i := 0; i < *flagSteps; i++ {
+		swarm.Update(i%2 == 0, target)
+		if err := e.Write(swarm); err != nil {
+			panic(err)
+		}
+	}
+	if err := e.Flush(); err != nil {
+		panic(err)
+	}
+}
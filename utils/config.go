@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// LoadConfig decodes a JSON or TOML file (decided by the file extension) into v.
+// Only fields present in the file are overwritten, so v can be prefilled with defaults.
+func LoadConfig(path string, v interface{}) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read config '%s': %s", path, err)
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		err = json.Unmarshal(b, v)
+	case ".toml":
+		err = toml.Unmarshal(b, v)
+	default:
+		return fmt.Errorf("unknown config format '%s' for '%s', expected .json or .toml", ext, path)
+	}
+	if err != nil {
+		return fmt.Errorf("could not decode config '%s': %s", path, err)
+	}
+	return nil
+}
+
+// Watcher polls a file's modification time, to detect when it has been changed.
+type Watcher struct {
+	path     string
+	interval time.Duration
+	checked  time.Time
+	modified time.Time
+}
+
+// NewWatcher returns a Watcher that checks the file at most once per interval.
+func NewWatcher(path string, interval time.Duration) (*Watcher, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Watcher{
+		path:     path,
+		interval: interval,
+		checked:  time.Now(),
+		modified: fi.ModTime(),
+	}, nil
+}
+
+// Changed returns true if the file has been modified since the last time it returned true.
+// It's cheap to call often, as the file is only checked once per interval.
+func (w *Watcher) Changed() bool {
+	if time.Since(w.checked) < w.interval {
+		return false
+	}
+	w.checked = time.Now()
+	fi, err := os.Stat(w.path)
+	if err != nil {
+		// Probably in the middle of being replaced, so try again later
+		return false
+	}
+	if fi.ModTime().Equal(w.modified) {
+		return false
+	}
+	w.modified = fi.ModTime()
+	return true
+}
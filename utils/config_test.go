@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lmas/akvarium/boids"
+)
+
+func TestLoadConfig(t *testing.T) {
+	files := map[string]string{
+		"conf.json": `{"Boids": 20, "Spawn": [{"X": 1, "Y": 2}, {"X": 3, "Y": 4}], "SpeedTrait": {"Kind": "uniform", "A": 0.5, "B": 1.5}}`,
+		"conf.toml": "Boids = 20\nSpawn = [{X = 1, Y = 2}, {X = 3, Y = 4}]\nSpeedTrait = {Kind = \"uniform\", A = 0.5, B = 1.5}\n",
+	}
+	dir := t.TempDir()
+	for name, body := range files {
+		t.Run(name, func(t *testing.T) {
+			p := filepath.Join(dir, name)
+			if err := os.WriteFile(p, []byte(body), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			conf := boids.DefaultConf()
+			if err := LoadConfig(p, &conf); err != nil {
+				t.Fatal(err)
+			}
+			if conf.Boids != 20 {
+				t.Errorf("got %d boids, expected 20", conf.Boids)
+			}
+			if conf.Spawn[1] != boids.NewVector(3, 4) {
+				t.Errorf("got spawn max %s, expected (3, 4)", conf.Spawn[1])
+			}
+			if conf.SpeedTrait != boids.Uniform(0.5, 1.5) {
+				t.Errorf("got speed trait %v, expected uniform 0.5-1.5", conf.SpeedTrait)
+			}
+			if conf.Workers != 10 {
+				t.Errorf("got %d workers, expected the default 10", conf.Workers)
+			}
+		})
+	}
+
+	p := filepath.Join(dir, "conf.yaml")
+	if err := os.WriteFile(p, []byte("Boids: 20\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadConfig(p, &struct{}{}); err == nil {
+		t.Error("got no error, expected unknown format")
+	}
+}
+
+func TestLoadSwarm(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "conf.toml")
+	if err := os.WriteFile(config, []byte("[Swarm]\nBoids = 20\nCohesionFactor = 0.5\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := LoadSwarm("", config, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	preset, _ := boids.PresetConf(DefaultPreset)
+	if len(s.Boids) != 20 || s.Conf.CohesionFactor != 0.5 || s.Conf.MaxTurnRate != preset.MaxTurnRate {
+		t.Errorf("got %d boids and cohesion %v, expected 20 and 0.5 on top of the default preset",
+			len(s.Boids), s.Conf.CohesionFactor)
+	}
+	if _, err := LoadSwarm("nope", "", ""); err == nil {
+		t.Error("got no error, expected unknown preset")
+	}
+
+	t.Run("snapshot", func(t *testing.T) {
+		snapshot := filepath.Join(dir, "swarm.snap")
+		if err := SaveSnapshot(snapshot, s.Snapshot()); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadSwarm("baitball", "", snapshot); err == nil {
+			t.Error("got no error, expected a preset to be rejected with a snapshot")
+		}
+		other := filepath.Join(dir, "other.toml")
+		if err := os.WriteFile(other, []byte("[Swarm]\nBoids = 30\nCohesionFactor = 0.25\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		r, err := LoadSwarm("", other, snapshot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		// The layout is kept from the snapshot, while the rest of the config is applied on top
+		conf := r.CurrentConf()
+		if len(r.Boids) != 20 || conf.CohesionFactor != 0.25 || r.Tick() != s.Tick() {
+			t.Errorf("got %d boids and cohesion %v, expected 20 and 0.25", len(r.Boids), conf.CohesionFactor)
+		}
+	})
+}
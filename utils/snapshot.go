@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lmas/akvarium/boids"
+)
+
+// SaveSnapshot writes a Snapshot to a file, as JSON if the file extension is ".json" or else
+// in the binary format. The file is replaced atomically, so a crash can't leave half a
+// snapshot behind.
+func SaveSnapshot(path string, snap *boids.Snapshot) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("could not save snapshot '%s': %s", path, err)
+	}
+	defer os.Remove(f.Name())
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		err = json.NewEncoder(f).Encode(snap)
+	} else {
+		_, err = snap.WriteTo(f)
+	}
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("could not save snapshot '%s': %s", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("could not save snapshot '%s': %s", path, err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("could not save snapshot '%s': %s", path, err)
+	}
+	return nil
+}
+
+// LoadSnapshot reads a Snapshot from a file, saved by SaveSnapshot.
+func LoadSnapshot(path string) (*boids.Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not load snapshot '%s': %s", path, err)
+	}
+	defer f.Close()
+	var snap *boids.Snapshot
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		snap = &boids.Snapshot{}
+		err = json.NewDecoder(f).Decode(snap)
+	} else {
+		snap, err = boids.ReadSnapshot(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not load snapshot '%s': %s", path, err)
+	}
+	return snap, nil
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"fmt"
+
+	"github.com/lmas/akvarium/boids"
+)
+
+// DefaultPreset is used by LoadSwarm when no preset is given.
+const DefaultPreset string = "school"
+
+// LoadSwarm creates a new Swarm from a named preset (or DefaultPreset if empty), with any changes
+// from a config file on top. The config file has the same format as the main simulation's, with
+// the Swarm's conf in it's Swarm section.
+//
+// If a snapshot is given, the Swarm is restored from it instead and any changes from the config
+// file are applied on top of the snapshot's conf at the first Update (see Swarm.SetConf, which
+// keeps the layout). As a snapshot has it's own conf it can't be combined with a preset.
+func LoadSwarm(preset, config, snapshot string) (*boids.Swarm, error) {
+	if snapshot != "" {
+		if preset != "" {
+			return nil, fmt.Errorf("can't use preset '%s' with snapshot '%s', as it has it's own conf", preset, snapshot)
+		}
+		snap, err := LoadSnapshot(snapshot)
+		if err != nil {
+			return nil, err
+		}
+		s, err := boids.Restore(snap)
+		if err != nil {
+			return nil, fmt.Errorf("could not restore snapshot '%s': %s", snapshot, err)
+		}
+		if config == "" {
+			return s, nil
+		}
+		conf := struct{ Swarm boids.Conf }{s.Conf}
+		if err := LoadConfig(config, &conf); err != nil {
+			s.Close()
+			return nil, err
+		}
+		if err := s.SetConf(conf.Swarm); err != nil {
+			s.Close()
+			return nil, fmt.Errorf("could not apply config '%s': %s", config, err)
+		}
+		return s, nil
+	}
+
+	if preset == "" {
+		preset = DefaultPreset
+	}
+	c, err := boids.PresetConf(preset)
+	if err != nil {
+		return nil, err
+	}
+	conf := struct{ Swarm boids.Conf }{c}
+	if config != "" {
+		if err := LoadConfig(config, &conf); err != nil {
+			return nil, err
+		}
+	}
+	return boids.New(conf.Swarm)
+}